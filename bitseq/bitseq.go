@@ -0,0 +1,120 @@
+// Package bitseq packs genomes into bit planes, so that the sites where
+// two genomes differ can be found a 64-bit word at a time with XOR and
+// popcount instead of one byte comparison per site.
+package bitseq
+
+import (
+	"github.com/mingzhi/gomain/sched"
+	"math/bits"
+)
+
+// Genomes are sequences of the same length packed into bit planes.
+// Every distinct byte of the sequences gets a code of as many bits as
+// its alphabet needs, two for nucleotides, and plane p holds bit p of
+// the code of every site. Two genomes differ at a site if they differ
+// in any plane there.
+type Genomes struct {
+	n      int      // number of genomes
+	length int      // number of sites
+	words  int      // 64-bit words per plane
+	planes int      // bits per site
+	bits   []uint64 // word w of plane p of genome i at (i*words+w)*planes+p
+}
+
+// Pack packs seqs, which must all have the same length.
+func Pack(seqs [][]byte) *Genomes {
+	return pack(seqs, 1)
+}
+
+// pack packs seqs on at most sched.Jobs(jobs) goroutines.
+func pack(seqs [][]byte, jobs int) *Genomes {
+	g := &Genomes{n: len(seqs)}
+	if g.n > 0 {
+		g.length = len(seqs[0])
+	}
+
+	// code the bytes in order of appearance
+	var code [256]uint
+	var seen [256]bool
+	symbols := uint(0)
+	for _, s := range seqs {
+		if len(s) != g.length {
+			panic("bitseq: sequences of different lengths")
+		}
+		for _, c := range s {
+			if !seen[c] {
+				seen[c] = true
+				code[c] = symbols
+				symbols++
+			}
+		}
+	}
+	g.planes = 1
+	for 1<<uint(g.planes) < symbols {
+		g.planes++
+	}
+
+	g.words = (g.length + 63) / 64
+	g.bits = make([]uint64, g.n*g.words*g.planes)
+	sched.Run(g.n, jobs, func(i int) {
+		s := seqs[i]
+		for w := 0; w < g.words; w++ {
+			var planes [8]uint64 // a byte has at most 8 bits of code
+			end := (w + 1) * 64
+			if end > g.length {
+				end = g.length
+			}
+			for k := w * 64; k < end; k++ {
+				x := uint64(code[s[k]])
+				for p := 0; p < g.planes; p++ {
+					planes[p] |= (x >> uint(p) & 1) << uint(k%64)
+				}
+			}
+			copy(g.bits[(i*g.words+w)*g.planes:], planes[:g.planes])
+		}
+	})
+	return g
+}
+
+// Len returns the number of genomes.
+func (g *Genomes) Len() int {
+	return g.n
+}
+
+// Length returns the number of sites of a genome.
+func (g *Genomes) Length() int {
+	return g.length
+}
+
+// xor returns the mask of the sites of word w where genomes a and b differ.
+func (g *Genomes) xor(a, b, w int) uint64 {
+	x := g.bits[(a*g.words+w)*g.planes:]
+	y := g.bits[(b*g.words+w)*g.planes:]
+	var d uint64
+	for p := 0; p < g.planes; p++ {
+		d |= x[p] ^ y[p]
+	}
+	return d
+}
+
+// Count returns the number of sites where genomes a and b differ.
+func (g *Genomes) Count(a, b int) int {
+	n := 0
+	for w := 0; w < g.words; w++ {
+		n += bits.OnesCount64(g.xor(a, b, w))
+	}
+	return n
+}
+
+// Diff appends the sites where genomes a and b differ to dst,
+// in increasing order, and returns the extended slice.
+func (g *Genomes) Diff(dst []int, a, b int) []int {
+	for w := 0; w < g.words; w++ {
+		d := g.xor(a, b, w)
+		for d != 0 {
+			dst = append(dst, w*64+bits.TrailingZeros64(d))
+			d &= d - 1
+		}
+	}
+	return dst
+}
@@ -0,0 +1,171 @@
+package bitseq
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// naiveDiff returns the sites where a and b differ, one byte at a time.
+func naiveDiff(a, b []byte) []int {
+	diff := []int{}
+	for k := range a {
+		if a[k] != b[k] {
+			diff = append(diff, k)
+		}
+	}
+	return diff
+}
+
+func equal(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mutants returns n copies of a random ancestor over alphabet, each with
+// a fraction diversity of its sites redrawn.
+func mutants(n, length int, alphabet string, diversity float64, r *rand.Rand) [][]byte {
+	ancestor := make([]byte, length)
+	for k := range ancestor {
+		ancestor[k] = alphabet[r.Intn(len(alphabet))]
+	}
+	seqs := make([][]byte, n)
+	for i := range seqs {
+		s := append([]byte(nil), ancestor...)
+		for k := range s {
+			if r.Float64() < diversity {
+				s[k] = alphabet[r.Intn(len(alphabet))]
+			}
+		}
+		seqs[i] = s
+	}
+	return seqs
+}
+
+var diffCases = []struct {
+	length    int
+	alphabet  string
+	diversity float64
+}{
+	{0, "ATGC", 0.5},
+	{1, "ATGC", 0.5},
+	{63, "ATGC", 0.3},
+	{64, "ATGC", 0.3},
+	{65, "ATGC", 0.3},
+	{130, "ATGC", 0.3},
+	{200, "A", 0.3},
+	{200, "AT", 0.3},
+	{130, "ATGCN", 0.3},
+	{130, "ATGC-NRY", 0.3},
+	{257, "ACDEFGHIKLMNPQRSTVWY", 0.3},
+	{1000, "ATGC", 0.01},
+}
+
+func TestDiff(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range diffCases {
+		seqs := mutants(6, c.length, c.alphabet, c.diversity, r)
+		g := Pack(seqs)
+		if g.Len() != len(seqs) || g.Length() != c.length {
+			t.Errorf("%d sites of %q: packed %d genomes of %d sites", c.length, c.alphabet, g.Len(), g.Length())
+		}
+		for a := range seqs {
+			for b := range seqs {
+				want := naiveDiff(seqs[a], seqs[b])
+				got := g.Diff(nil, a, b)
+				if !equal(got, want) {
+					t.Errorf("%d sites of %q, pair %d-%d: Diff = %v, want %v", c.length, c.alphabet, a, b, got, want)
+				}
+				if n := g.Count(a, b); n != len(want) {
+					t.Errorf("%d sites of %q, pair %d-%d: Count = %d, want %d", c.length, c.alphabet, a, b, n, len(want))
+				}
+			}
+		}
+	}
+}
+
+func TestDiffMatrix(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for _, c := range diffCases {
+		seqs := mutants(8, c.length, c.alphabet, c.diversity, r)
+		// every pair of a subset, and pairs drawn with repeats
+		pairs := SubsetPairs([]int{6, 1, 3, 4})
+		for i := 0; i < 10; i++ {
+			pairs = append(pairs, Pair{r.Intn(len(seqs)), r.Intn(len(seqs))})
+		}
+		for _, jobs := range []int{1, 3, 0} {
+			got := DiffMatrix(seqs, pairs, jobs)
+			if len(got) != len(pairs) {
+				t.Fatalf("%d sites of %q: %d diffs for %d pairs", c.length, c.alphabet, len(got), len(pairs))
+			}
+			for i, p := range pairs {
+				want := naiveDiff(seqs[p[0]], seqs[p[1]])
+				if !equal(got[i], want) {
+					t.Errorf("%d sites of %q, %d jobs, pair %v: %v, want %v", c.length, c.alphabet, jobs, p, got[i], want)
+				}
+			}
+		}
+	}
+}
+
+func TestDiffMatrixEmpty(t *testing.T) {
+	if got := DiffMatrix(nil, nil, 0); len(got) != 0 {
+		t.Errorf("no genomes: %v, want no diffs", got)
+	}
+	seqs := [][]byte{{}, {}, {}}
+	got := DiffMatrix(seqs, AllPairs(len(seqs)), 0)
+	if len(got) != 3 {
+		t.Fatalf("empty genomes: %d diffs, want 3", len(got))
+	}
+	for i, d := range got {
+		if len(d) != 0 {
+			t.Errorf("empty genomes, pair %d: %v, want none", i, d)
+		}
+	}
+}
+
+func TestPackLengths(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Pack of sequences of different lengths did not panic")
+		}
+	}()
+	Pack([][]byte{[]byte("ATG"), []byte("AT")})
+}
+
+func BenchmarkDiff(b *testing.B) {
+	for _, length := range []int{1000, 100000} {
+		seqs := mutants(2, length, "ATGC", 0.01, rand.New(rand.NewSource(1)))
+		g := Pack(seqs)
+		b.Run(fmt.Sprintf("naive/%d", length), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				naiveDiff(seqs[0], seqs[1])
+			}
+		})
+		b.Run(fmt.Sprintf("packed/%d", length), func(b *testing.B) {
+			var dst []int
+			for i := 0; i < b.N; i++ {
+				dst = g.Diff(dst[:0], 0, 1)
+			}
+		})
+	}
+}
+
+func BenchmarkDiffMatrix(b *testing.B) {
+	seqs := mutants(100, 10000, "ATGC", 0.01, rand.New(rand.NewSource(1)))
+	pairs := AllPairs(len(seqs))
+	for _, jobs := range []int{1, 0} {
+		b.Run(fmt.Sprintf("jobs%d", jobs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				DiffMatrix(seqs, pairs, jobs)
+			}
+		})
+	}
+}
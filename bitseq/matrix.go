@@ -0,0 +1,81 @@
+package bitseq
+
+import (
+	"github.com/mingzhi/gomain/sched"
+)
+
+// Pair is a pair of genomes, by index.
+type Pair [2]int
+
+// AllPairs returns the pairs j < k of n genomes, ordered by j then k.
+func AllPairs(n int) []Pair {
+	return SubsetPairs(identity(n))
+}
+
+// SubsetPairs returns the pairs of the genomes idx[j] and idx[k], j < k,
+// ordered by j then k.
+func SubsetPairs(idx []int) []Pair {
+	pairs := make([]Pair, 0, len(idx)*(len(idx)-1)/2)
+	for j := 0; j < len(idx); j++ {
+		for k := j + 1; k < len(idx); k++ {
+			pairs = append(pairs, Pair{idx[j], idx[k]})
+		}
+	}
+	return pairs
+}
+
+func identity(n int) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// Matrix returns the sites where the genomes of every pair differ, in the
+// order of pairs, as covs.NewCMatrix expects them. The pairs are split over
+// at most sched.Jobs(jobs) goroutines. The diffs are counted first and
+// then written into one buffer sized to hold them all.
+func (g *Genomes) Matrix(pairs []Pair, jobs int) [][]int {
+	counts := make([]int, len(pairs))
+	sched.Run(len(pairs), jobs, func(i int) {
+		counts[i] = g.Count(pairs[i][0], pairs[i][1])
+	})
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+
+	buf := make([]int, total)
+	diffs := make([][]int, len(pairs))
+	for i, n := range counts {
+		diffs[i], buf = buf[:0:n], buf[n:]
+	}
+	sched.Run(len(pairs), jobs, func(i int) {
+		diffs[i] = g.Diff(diffs[i], pairs[i][0], pairs[i][1])
+	})
+	return diffs
+}
+
+// DiffMatrix packs the genomes of seqs that are in pairs and returns
+// their diff matrix, see Matrix. jobs is the number of goroutines,
+// 0 for the number of CPUs; drivers that already run replicates
+// in parallel should pass 1.
+func DiffMatrix(seqs [][]byte, pairs []Pair, jobs int) [][]int {
+	// pack only the genomes the pairs use
+	index := make(map[int]int)
+	sample := [][]byte{}
+	local := make([]Pair, len(pairs))
+	for i, p := range pairs {
+		for j, a := range p {
+			k, ok := index[a]
+			if !ok {
+				k = len(sample)
+				index[a] = k
+				sample = append(sample, seqs[a])
+			}
+			local[i][j] = k
+		}
+	}
+	return pack(sample, jobs).Matrix(local, jobs)
+}
@@ -0,0 +1,60 @@
+// Package checkpoint saves and restores the state of long simulations,
+// so that a killed job can be resumed where it stopped.
+package checkpoint
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Save writes v as JSON to filename. It writes a temporary file first
+// and renames it, so an interrupted save never leaves a broken checkpoint.
+func Save(filename string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// Load reads the JSON checkpoint in filename into v.
+func Load(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Offset syncs f and returns the number of bytes written so far.
+func Offset(f *os.File) (int64, error) {
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return f.Seek(0, io.SeekCurrent)
+}
+
+// Reopen opens filename for writing after cutting it to size bytes,
+// dropping whatever was written after the checkpoint.
+func Reopen(filename string, size int64) (*os.File, error) {
+	f, err := os.OpenFile(filename, os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,93 @@
+package checkpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type state struct {
+	Next  int
+	Means []float64
+	Size  int64
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestSaveLoad(t *testing.T) {
+	filename := filepath.Join(tempDir(t), "run.ckpt")
+	for _, want := range []state{
+		{Next: 3, Means: []float64{0.5, 1e-9}, Size: 120},
+		{Next: 7, Means: []float64{2}, Size: 300},
+	} {
+		if err := Save(filename, &want); err != nil {
+			t.Fatal(err)
+		}
+		var got state
+		if err := Load(filename, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("loaded %+v, saved %+v", got, want)
+		}
+	}
+	if _, err := os.Stat(filename + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left after Save: %v", err)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	var s state
+	if err := Load(filepath.Join(tempDir(t), "none.ckpt"), &s); err == nil {
+		t.Error("loaded a missing checkpoint without error")
+	}
+}
+
+func TestOffsetReopen(t *testing.T) {
+	filename := filepath.Join(tempDir(t), "run_d.csv")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("#ks, vd\n0.1,0.2\n")
+	size, err := Offset(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 16 {
+		t.Errorf("Offset = %d, want 16", size)
+	}
+	// rows written after the checkpoint, then the job is killed
+	f.WriteString("0.3,0.4\n0.5,")
+	f.Close()
+
+	f, err = Reopen(filename, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("0.7,0.8\n")
+	f.Close()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#ks, vd\n0.1,0.2\n0.7,0.8\n"; string(data) != want {
+		t.Errorf("file after Reopen = %q, want %q", data, want)
+	}
+}
+
+func TestReopenMissing(t *testing.T) {
+	if _, err := Reopen(filepath.Join(tempDir(t), "none_d.csv"), 0); err == nil {
+		t.Error("reopened a missing file without error")
+	}
+}
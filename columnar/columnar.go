@@ -0,0 +1,180 @@
+// Package columnar writes the results of a run or a sweep into one Parquet
+// file, as a long table with the parameters attached to every row, so that
+// whole experiments can be loaded at once by pandas, R or DuckDB.
+package columnar
+
+import (
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/stats"
+	"github.com/mingzhi/gomain/window"
+	"github.com/parquet-go/parquet-go"
+	"os"
+)
+
+// Row is one value of the table. The rows of a _d.csv file have the
+// quantities ks and vd and a dist of -1; the rows of the covariance
+// curves have the quantities <series>_mean, _sd, _se and _n and a
+// replicate and generation of -1. The rows of a window of the genome
+// have its first site as start and its number of sites as length;
+// the other rows have a start of 0.
+type Row struct {
+	Size       int64   `parquet:"size"`
+	Length     int64   `parquet:"length"`
+	Start      int64   `parquet:"start"`
+	Fragment   int64   `parquet:"fragment"`
+	Mutation   float64 `parquet:"mutation"`
+	Transfer   float64 `parquet:"transfer"`
+	Replicate  int64   `parquet:"replicate"`
+	Generation int64   `parquet:"generation"`
+	Dist       int64   `parquet:"dist"`
+	Quantity   string  `parquet:"quantity,dict"`
+	Value      float64 `parquet:"value"`
+}
+
+// Params are the parameter values attached to the rows.
+type Params struct {
+	Size     int     `json:"size"`
+	Length   int     `json:"length"`
+	Fragment int     `json:"fragment"`
+	Mutation float64 `json:"mutation"`
+	Transfer float64 `json:"transfer"`
+}
+
+// ParamsOf returns the parameter values of cfg.
+func ParamsOf(cfg *config.Config) Params {
+	return Params{cfg.Size, cfg.Length, cfg.Fragment, cfg.Mutation, cfg.Transfer}
+}
+
+// Writer writes rows to a Parquet file.
+type Writer struct {
+	f *os.File
+	w *parquet.Writer
+}
+
+// Create creates a Parquet file and stores the header lines
+// in its key-value metadata.
+func Create(filename string, h *results.Header) (*Writer, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	options := []parquet.WriterOption{parquet.SchemaOf(new(Row))}
+	for _, key := range h.Keys() {
+		v, _ := h.Get(key)
+		options = append(options, parquet.KeyValueMetadata(key, v))
+	}
+	return &Writer{f: f, w: parquet.NewWriter(f, options...)}, nil
+}
+
+func (w *Writer) row(p Params, start, replicate, generation, dist int, quantity string, value float64) error {
+	return w.w.Write(&Row{
+		Size:       int64(p.Size),
+		Length:     int64(p.Length),
+		Start:      int64(start),
+		Fragment:   int64(p.Fragment),
+		Mutation:   p.Mutation,
+		Transfer:   p.Transfer,
+		Replicate:  int64(replicate),
+		Generation: int64(generation),
+		Dist:       int64(dist),
+		Quantity:   quantity,
+		Value:      value,
+	})
+}
+
+// D writes ks and vd of a replicate at a generation.
+func (w *Writer) D(p Params, replicate, generation int, ks, vd float64) error {
+	if err := w.row(p, 0, replicate, generation, -1, "ks", ks); err != nil {
+		return err
+	}
+	return w.row(p, 0, replicate, generation, -1, "vd", vd)
+}
+
+// Windows writes ks and vd of every window of a replicate at a generation.
+func (w *Writer) Windows(p Params, replicate, generation int, stats []window.Stat) error {
+	for _, s := range stats {
+		p.Length = s.Length()
+		if err := w.row(p, s.Start, replicate, generation, -1, "ks", s.KS); err != nil {
+			return err
+		}
+		if err := w.row(p, s.Start, replicate, generation, -1, "vd", s.VD); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Scan writes the tracks of a genome scan of a sample at a generation:
+// ks and vd of every window, and its covariance series with the quantity
+// named after the series and a replicate and generation of -1.
+func (w *Writer) Scan(p Params, generation int, tracks []window.Track) error {
+	for _, t := range tracks {
+		if err := w.Windows(p, 0, generation, []window.Stat{t.Stat}); err != nil {
+			return err
+		}
+		p.Length = t.Length()
+		for i, name := range stats.CovNames {
+			for l, v := range t.Series[i] {
+				if err := w.row(p, t.Start, -1, -1, l, name, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Covs writes the mean, standard deviation, standard error and count
+// of every covariance series at every distance.
+func (w *Writer) Covs(p Params, c *stats.Covs) error {
+	for i, name := range stats.CovNames {
+		for l := 0; l < c.MaxL(); l++ {
+			m := &c.Series[i][l]
+			values := []float64{m.Mean, m.Sd(), m.Se(), float64(m.N)}
+			for j, field := range []string{"_mean", "_sd", "_se", "_n"} {
+				if err := w.row(p, 0, -1, -1, l, name+field, values[j]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Close flushes the rows and closes the file.
+func (w *Writer) Close() error {
+	if err := w.w.Close(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// Save writes the rows of the _d.csv file dname and the covariance curves
+// of a run to filename. index gives the replicate and the generation
+// of the i-th row of the d file.
+func Save(filename string, cfg *config.Config, dname string, index func(i int) (replicate, generation int), c *stats.Covs) error {
+	h, ks, vd, err := results.ReadD(dname)
+	if err != nil {
+		return err
+	}
+	h.Add("replicates", c.N())
+	w, err := Create(filename, h)
+	if err != nil {
+		return err
+	}
+	p := ParamsOf(cfg)
+	for i := range ks {
+		replicate, generation := index(i)
+		if err := w.D(p, replicate, generation, ks[i], vd[i]); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	if err := w.Covs(p, c); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,458 @@
+// Package config holds the simulation parameters shared by the drivers,
+// registers them as command line flags and reads them from experiment files.
+//
+// An experiment file uses the same keys as the output headers, e.g. in YAML:
+//
+//	size: 1000
+//	length: 10000
+//	mutation: 1e-4
+//	transfer: 1e-4
+//	fragment: 100
+//	generations: 10000
+//	prefix: out/test
+//	sweep:
+//	  size: [100, 1000, 10000]
+//
+// Flags given on the command line override the values in the file.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/mingzhi/gomain/results"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds the population, sampling and output parameters.
+type Config struct {
+	Size       int     `json:"size" yaml:"size" toml:"size"`                      // population size
+	Length     int     `json:"length" yaml:"length" toml:"length"`                // genome length
+	Fragment   int     `json:"fragment" yaml:"fragment" toml:"fragment"`          // fragment length to transfer
+	Mutation   float64 `json:"mutation" yaml:"mutation" toml:"mutation"`          // mutation rate per site per generation
+	Transfer   float64 `json:"transfer" yaml:"transfer" toml:"transfer"`          // transfer rate per site per generation
+	MaxL       int     `json:"maxl" yaml:"maxl" toml:"maxl"`                      // max distance to calculate
+	Sample     int     `json:"sample" yaml:"sample" toml:"sample"`                // sample size or number of pairs to calculate
+	Pairs      string  `json:"pairs" yaml:"pairs" toml:"pairs"`                   // pair sampling: replace, distinct, subset or all
+	Gens       int     `json:"generations" yaml:"generations" toml:"generations"` // number of generations
+	EqvGens    int     `json:"eqv" yaml:"eqv" toml:"eqv"`                         // generations to reach equilibrium
+	Reps       int     `json:"repeats" yaml:"repeats" toml:"repeats"`             // number of replicates
+	ExpTime    bool    `json:"exptime" yaml:"exptime" toml:"exptime"`             // Exp time for Wright-Fisher selection
+	Linear     bool    `json:"linear" yaml:"linear" toml:"linear"`                // linear genome, no wraparound of distances
+	Window     int     `json:"window" yaml:"window" toml:"window"`                // size of the windows along the genome, 0 for none
+	Step       int     `json:"step" yaml:"step" toml:"step"`                      // sites between the starts of sliding windows, 0 for the window size
+	Seed       int64   `json:"seed" yaml:"seed" toml:"seed"`                      // master seed of all random streams
+	Checkpoint int     `json:"checkpoint" yaml:"checkpoint" toml:"checkpoint"`    // generations or replicates between checkpoints
+	Jobs       int     `json:"jobs" yaml:"jobs" toml:"jobs"`                      // number of replicates or points simulated at once
+	Prefix     string  `json:"prefix" yaml:"prefix" toml:"prefix"`                // output prefix
+	Parquet    bool    `json:"parquet" yaml:"parquet" toml:"parquet"`             // also write the results to one Parquet file
+	DB         string  `json:"db" yaml:"db" toml:"db"`                            // SQLite database to record the run in
+	Events     string  `json:"events" yaml:"events" toml:"events"`                // file of the JSON Lines event stream, "-" for stdout
+	Sweep      Sweep   `json:"sweep" yaml:"sweep" toml:"sweep"`                   // values to sweep over
+
+	Export       string `json:"export" yaml:"export" toml:"export"`                      // format of the exported genomes: fasta, phylip, ms or vcf
+	ExportSample int    `json:"export_sample" yaml:"export_sample" toml:"export_sample"` // number of genomes to export, 0 for all
+
+	File   string `json:"-" yaml:"-" toml:"-"` // experiment file
+	Resume bool   `json:"-" yaml:"-" toml:"-"` // resume from the last checkpoint
+	Shard  string `json:"-" yaml:"-" toml:"-"` // shard of a job array, "i/n", "n" or "auto"
+
+	shardable  bool // the driver supports shards
+	shardIndex int  // index of this shard
+	shardCount int  // number of shards, 0 if not sharded
+}
+
+// Sweep lists the parameter values of a sweep.
+type Sweep struct {
+	Size     Ints   `json:"size" yaml:"size" toml:"size"`
+	Length   Ints   `json:"length" yaml:"length" toml:"length"`
+	Fragment Ints   `json:"fragment" yaml:"fragment" toml:"fragment"`
+	Mutation Floats `json:"mutation" yaml:"mutation" toml:"mutation"`
+	Transfer Floats `json:"transfer" yaml:"transfer" toml:"transfer"`
+	Mode     string `json:"mode" yaml:"mode" toml:"mode"` // "grid" or "list"
+}
+
+// RegisterFlags registers the flags shared by all drivers,
+// using the current values of c as defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.File, "config", c.File, "experiment file (.json, .yaml or .toml)")
+	fs.IntVar(&c.Size, "size", c.Size, "population size")
+	fs.IntVar(&c.Length, "genome", c.Length, "genome length")
+	fs.IntVar(&c.Fragment, "frag", c.Fragment, "fragment length to transfer")
+	fs.IntVar(&c.MaxL, "maxl", c.MaxL, "max distance to calculate")
+	fs.Float64Var(&c.Mutation, "mutation", c.Mutation, "mutation rate per site per generation")
+	fs.Float64Var(&c.Transfer, "transfer", c.Transfer, "transfer rate per site per generation")
+	fs.StringVar(&c.Prefix, "prefix", c.Prefix, "prefix")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "master seed of all random streams")
+}
+
+// RegisterJobsFlag registers the -jobs flag of the parallel drivers.
+func (c *Config) RegisterJobsFlag(fs *flag.FlagSet) {
+	fs.IntVar(&c.Jobs, "jobs", c.Jobs, "number of replicates to simulate at once (0: number of CPUs)")
+}
+
+// RegisterLinearFlag registers the -linear flag of the drivers
+// that compute covariances.
+func (c *Config) RegisterLinearFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Linear, "linear", c.Linear, "treat the genome as linear instead of circular")
+}
+
+// RegisterPairsFlag registers the -pairs flag of the drivers
+// that draw pairs of genomes from a population.
+func (c *Config) RegisterPairsFlag(fs *flag.FlagSet) {
+	fs.StringVar(&c.Pairs, "pairs", c.Pairs,
+		"pair sampling: replace or distinct (-sample random pairs), subset (all pairs of -sample random genomes) or all")
+}
+
+// RegisterWindowFlag registers the -window flag of the drivers
+// that compute statistics in windows along the genome.
+func (c *Config) RegisterWindowFlag(fs *flag.FlagSet) {
+	fs.IntVar(&c.Window, "window", c.Window, "size of the windows along the genome")
+}
+
+// RegisterStepFlag registers the -step flag of the drivers
+// that scan the genome in sliding windows.
+func (c *Config) RegisterStepFlag(fs *flag.FlagSet) {
+	fs.IntVar(&c.Step, "step", c.Step, "sites between the starts of consecutive windows (0: the window size)")
+}
+
+// RegisterParquetFlag registers the -parquet flag of the drivers
+// that can write their results to a Parquet file.
+func (c *Config) RegisterParquetFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Parquet, "parquet", c.Parquet, "also write all the results to <prefix>.parquet")
+}
+
+// RegisterDBFlag registers the -db flag of the drivers
+// that can record their runs in a database.
+func (c *Config) RegisterDBFlag(fs *flag.FlagSet) {
+	fs.StringVar(&c.DB, "db", c.DB, "record the run and its results in this SQLite database")
+}
+
+// RegisterEventsFlag registers the -events flag of the drivers
+// that can stream their progress.
+func (c *Config) RegisterEventsFlag(fs *flag.FlagSet) {
+	fs.StringVar(&c.Events, "events", c.Events, "stream one JSON event per replicate or generation to this file (-: stdout)")
+}
+
+// RegisterShardFlag registers the -shard flag of the drivers
+// whose replicates can be split over a job array.
+func (c *Config) RegisterShardFlag(fs *flag.FlagSet) {
+	c.shardable = true
+	fs.StringVar(&c.Shard, "shard", c.Shard,
+		"simulate shard i of n of the replicates, as i/n, as n with i taken from SLURM_ARRAY_TASK_ID or PBS_ARRAYID, or as auto inside a SLURM array")
+}
+
+// RegisterExportFlags registers the flags of the drivers
+// that can export the simulated genomes.
+func (c *Config) RegisterExportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Export, "export", c.Export, "export the sampled genomes as fasta, phylip, ms or vcf")
+	fs.IntVar(&c.ExportSample, "export-sample", c.ExportSample, "number of genomes to export (0: all)")
+}
+
+// Parse parses args into c. If an experiment file is given,
+// its values replace the defaults and the flags in args override them.
+func (c *Config) Parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.File != "" {
+		if err := c.Load(c.File); err != nil {
+			return err
+		}
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+	}
+	switch c.Export {
+	case "", "fasta", "phylip", "ms", "vcf":
+	default:
+		return fmt.Errorf("unknown export format: %s", c.Export)
+	}
+	return c.resolveShard()
+}
+
+// Load reads the experiment file into c. The format is chosen
+// by the file extension.
+func (c *Config) Load(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		err = json.Unmarshal(data, c)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		_, err = toml.Decode(string(data), c)
+	default:
+		err = fmt.Errorf("unknown experiment file format: %s", filename)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	return nil
+}
+
+// AdjustMaxL makes sure maxl covers at least two fragment lengths.
+func (c *Config) AdjustMaxL() {
+	if c.MaxL < 2*c.Fragment {
+		c.MaxL = 2 * c.Fragment
+	}
+}
+
+// CheckResume returns an error if a run with c cannot continue the
+// checkpoint of a run with saved. They must agree on every parameter
+// that changes the results or where the checkpoints are taken.
+func (c *Config) CheckResume(saved *Config) error {
+	params := []struct {
+		key       string
+		now, then interface{}
+	}{
+		{"size", c.Size, saved.Size},
+		{"length", c.Length, saved.Length},
+		{"fragment", c.Fragment, saved.Fragment},
+		{"mutation", c.Mutation, saved.Mutation},
+		{"transfer", c.Transfer, saved.Transfer},
+		{"maxl", c.MaxL, saved.MaxL},
+		{"sample", c.Sample, saved.Sample},
+		{"pairs", c.Pairs, saved.Pairs},
+		{"generations", c.Gens, saved.Gens},
+		{"eqv", c.EqvGens, saved.EqvGens},
+		{"repeats", c.Reps, saved.Reps},
+		{"exptime", c.ExpTime, saved.ExpTime},
+		{"linear", c.Linear, saved.Linear},
+		{"seed", c.Seed, saved.Seed},
+		{"checkpoint", c.Checkpoint, saved.Checkpoint},
+	}
+	for _, p := range params {
+		if p.now != p.then {
+			return fmt.Errorf("cannot resume: %s is %v in the checkpoint but %v now", p.key, p.then, p.now)
+		}
+	}
+	return nil
+}
+
+// resolveShard works out the shard index and count from -shard
+// and the job array environment variables.
+func (c *Config) resolveShard() error {
+	if !c.shardable {
+		return nil
+	}
+
+	// the job array variables are only used when asked for, so that
+	// an array of independent runs is not split up behind their back
+	s := c.Shard
+	if s == "" {
+		return nil
+	}
+	if s == "auto" {
+		// a SLURM array knows its own size
+		s = os.Getenv("SLURM_ARRAY_TASK_COUNT")
+		if s == "" {
+			return fmt.Errorf("-shard auto needs SLURM_ARRAY_TASK_COUNT; give the number of shards instead")
+		}
+	}
+
+	var err error
+	parts := strings.Split(s, "/")
+	switch len(parts) {
+	case 1:
+		c.shardCount, err = strconv.Atoi(parts[0])
+		if err == nil {
+			c.shardIndex, err = arrayIndex()
+		}
+	case 2:
+		c.shardIndex, err = strconv.Atoi(parts[0])
+		if err == nil {
+			c.shardCount, err = strconv.Atoi(parts[1])
+		}
+	default:
+		err = fmt.Errorf("bad shard: %s", s)
+	}
+	if err != nil {
+		return err
+	}
+	if c.shardCount < 1 || c.shardIndex < 0 || c.shardIndex >= c.shardCount {
+		return fmt.Errorf("bad shard: %d/%d", c.shardIndex, c.shardCount)
+	}
+	return nil
+}
+
+// arrayIndex returns the zero based index of the job in a SLURM or PBS job array.
+func arrayIndex() (int, error) {
+	if id := os.Getenv("SLURM_ARRAY_TASK_ID"); id != "" {
+		i, err := strconv.Atoi(id)
+		if err != nil {
+			return 0, err
+		}
+		if min := os.Getenv("SLURM_ARRAY_TASK_MIN"); min != "" {
+			m, err := strconv.Atoi(min)
+			if err != nil {
+				return 0, err
+			}
+			i -= m
+		}
+		return i, nil
+	}
+	for _, key := range []string{"PBS_ARRAYID", "PBS_ARRAY_INDEX"} {
+		if id := os.Getenv(key); id != "" {
+			return strconv.Atoi(id)
+		}
+	}
+	return 0, fmt.Errorf("no job array index in the environment")
+}
+
+// Sharded reports whether the run is one shard of a job array.
+func (c *Config) Sharded() bool {
+	return c.shardCount > 0
+}
+
+// ShardRange returns the replicates [b, e) of this shard out of n replicates.
+// Shards take contiguous ranges, so that the replicates keep the indices,
+// and hence the random streams, of a single run.
+func (c *Config) ShardRange(n int) (b, e int) {
+	if !c.Sharded() {
+		return 0, n
+	}
+	return c.shardIndex * n / c.shardCount, (c.shardIndex + 1) * n / c.shardCount
+}
+
+// Out returns the name of an output file: the prefix, the shard if any, and suffix.
+func (c *Config) Out(suffix string) string {
+	if !c.Sharded() {
+		return c.Prefix + suffix
+	}
+	return fmt.Sprintf("%s_shard%d%s", c.Prefix, c.shardIndex, suffix)
+}
+
+// Header returns the resolved parameters and the provenance of the run
+// as "#key: value" header lines. ReadInfo parses them back.
+//
+// The results do not depend on -jobs, so it is left out. Apart from the
+// provenance lines (ProvenanceKeys), which record when, where and how a
+// run was made, the output of a rerun with the same parameters and seed
+// is identical; compare outputs without those lines.
+func (c *Config) Header() *results.Header {
+	return c.HeaderWith(CurrentProvenance())
+}
+
+// HeaderWith returns the header lines of c with the provenance p,
+// such as that of the runs whose results are merged.
+func (c *Config) HeaderWith(p Provenance) *results.Header {
+	h := &results.Header{}
+	h.Add("size", c.Size)
+	h.Add("length", c.Length)
+	h.Add("fragment", c.Fragment)
+	h.Add("mutation", c.Mutation)
+	h.Add("transfer", c.Transfer)
+	h.Add("maxl", c.MaxL)
+	h.Add("sample", c.Sample)
+	if c.Pairs != "" {
+		h.Add("pairs", c.Pairs)
+	}
+	h.Add("generations", c.Gens)
+	h.Add("eqv", c.EqvGens)
+	h.Add("repeats", c.Reps)
+	h.Add("exptime", c.ExpTime)
+	h.Add("genome", GenomeMode(c.Linear))
+	if c.Window > 0 {
+		h.Add("window", c.Window)
+	}
+	if c.Step > 0 {
+		h.Add("step", c.Step)
+	}
+	h.Add("seed", c.Seed)
+	h.Add("checkpoint", c.Checkpoint)
+	h.Add("prefix", c.Prefix)
+	if c.Sharded() {
+		h.Add("shard", fmt.Sprintf("%d/%d", c.shardIndex, c.shardCount))
+	}
+	if c.Export != "" {
+		h.Add("export", c.Export)
+		h.Add("export_sample", c.ExportSample)
+	}
+	if len(c.Sweep.Size) > 0 {
+		h.Add("sweep.size", c.Sweep.Size.String())
+	}
+	if len(c.Sweep.Length) > 0 {
+		h.Add("sweep.length", c.Sweep.Length.String())
+	}
+	if len(c.Sweep.Fragment) > 0 {
+		h.Add("sweep.fragment", c.Sweep.Fragment.String())
+	}
+	if len(c.Sweep.Mutation) > 0 {
+		h.Add("sweep.mutation", c.Sweep.Mutation.String())
+	}
+	if len(c.Sweep.Transfer) > 0 {
+		h.Add("sweep.transfer", c.Sweep.Transfer.String())
+	}
+	if c.Sweep.Mode != "" {
+		h.Add("sweep.mode", c.Sweep.Mode)
+	}
+	p.add(h)
+	return h
+}
+
+// GenomeMode returns the name of the genome mode recorded in the headers.
+func GenomeMode(linear bool) string {
+	if linear {
+		return "linear"
+	}
+	return "circular"
+}
+
+// Ints is a comma separated list of ints, usable as a flag.
+type Ints []int
+
+func (v *Ints) String() string {
+	s := []string{}
+	for _, i := range *v {
+		s = append(s, strconv.Itoa(i))
+	}
+	return strings.Join(s, ",")
+}
+
+func (v *Ints) Set(s string) error {
+	l := Ints{}
+	for _, f := range strings.Split(s, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		l = append(l, i)
+	}
+	*v = l
+	return nil
+}
+
+// Floats is a comma separated list of float64s, usable as a flag.
+type Floats []float64
+
+func (v *Floats) String() string {
+	s := []string{}
+	for _, f := range *v {
+		s = append(s, strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return strings.Join(s, ",")
+}
+
+func (v *Floats) Set(s string) error {
+	l := Floats{}
+	for _, f := range strings.Split(s, ",") {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return err
+		}
+		l = append(l, x)
+	}
+	*v = l
+	return nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckResume(t *testing.T) {
+	saved := Config{Size: 100, Length: 1000, Fragment: 10, Mutation: 1e-4, Transfer: 1e-4,
+		MaxL: 50, Sample: 20, Pairs: "replace", Gens: 100, Reps: 10, Seed: 7, Checkpoint: 1}
+
+	same := saved
+	same.Jobs = 8
+	same.Resume = true
+	if err := same.CheckResume(&saved); err != nil {
+		t.Errorf("same parameters: %v", err)
+	}
+
+	changes := map[string]func(c *Config){
+		"size":       func(c *Config) { c.Size++ },
+		"mutation":   func(c *Config) { c.Mutation *= 2 },
+		"pairs":      func(c *Config) { c.Pairs = "distinct" },
+		"repeats":    func(c *Config) { c.Reps++ },
+		"linear":     func(c *Config) { c.Linear = true },
+		"seed":       func(c *Config) { c.Seed++ },
+		"checkpoint": func(c *Config) { c.Checkpoint = 5 },
+	}
+	for key, change := range changes {
+		c := saved
+		change(&c)
+		err := c.CheckResume(&saved)
+		if err == nil {
+			t.Errorf("changed %s: resumed without error", key)
+		} else if !strings.Contains(err.Error(), key) {
+			t.Errorf("changed %s: error %q does not name it", key, err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mingzhi/gomain/results"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// started is the start time of the process, recorded in every header it writes.
+var started = time.Now()
+
+// revision and hgtVersion, if set at link time, override the revision of
+// gomain and the version of hgt read from the build info, which are
+// unknown outside a module build:
+//
+//	go build -ldflags "-X github.com/mingzhi/gomain/config.revision=$(git rev-parse HEAD)
+//		-X github.com/mingzhi/gomain/config.hgtVersion=v0.1.0"
+var revision, hgtVersion string
+
+// Provenance records how and where a result was produced.
+type Provenance struct {
+	Command  string // command line
+	Time     string // start time of the process, RFC 3339
+	Revision string // revision of gomain
+	HGT      string // version of the hgt library
+	Go       string // version of Go
+	Host     string // host name
+}
+
+// ProvenanceKeys are the header keys of the provenance, which differ
+// between reruns and between the parts of a run split over hosts,
+// and of the process that merged the parts, if any.
+var ProvenanceKeys = []string{"command", "time", "revision", "hgt", "go", "host", "merge_command", "merge_time"}
+
+// CurrentProvenance returns the provenance of this process.
+func CurrentProvenance() Provenance {
+	host, _ := os.Hostname()
+	p := Provenance{
+		Command:  strings.Join(os.Args, " "),
+		Time:     started.Format(time.RFC3339),
+		Revision: "unknown",
+		HGT:      "unknown",
+		Go:       runtime.Version(),
+		Host:     host,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		p.Revision, p.HGT = buildVersions(info, p.Revision, p.HGT)
+	}
+	if revision != "" {
+		p.Revision = revision
+	}
+	if hgtVersion != "" {
+		p.HGT = hgtVersion
+	}
+	return p
+}
+
+// buildVersions returns the VCS revision of the main module and the version
+// of hgt recorded in info, or rev and hgt if they are not recorded.
+func buildVersions(info *debug.BuildInfo, rev, hgt string) (string, string) {
+	modified := false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified {
+		rev += "+dirty"
+	}
+	for _, d := range info.Deps {
+		if d.Path == "github.com/mingzhi/hgt" {
+			hgt = d.Version
+			if d.Replace != nil {
+				hgt = d.Replace.Path
+				if d.Replace.Version != "" {
+					hgt += "@" + d.Replace.Version
+				}
+			}
+		}
+	}
+	return rev, hgt
+}
+
+// MergeProvenance returns the provenance of results merged from parts
+// with the provenances ps. The parts must be built from the same revision
+// with the same hgt and Go versions; the distinct commands, times and
+// hosts of the parts are joined by "; ".
+func MergeProvenance(ps []Provenance) (Provenance, error) {
+	m := ps[0]
+	for i, p := range ps[1:] {
+		switch {
+		case p.Revision != m.Revision:
+			return m, fmt.Errorf("part %d has revision %s, part 0 has %s", i+1, p.Revision, m.Revision)
+		case p.HGT != m.HGT:
+			return m, fmt.Errorf("part %d has hgt %s, part 0 has %s", i+1, p.HGT, m.HGT)
+		case p.Go != m.Go:
+			return m, fmt.Errorf("part %d has go %s, part 0 has %s", i+1, p.Go, m.Go)
+		}
+	}
+	join := func(value func(p Provenance) string) string {
+		seen := map[string]bool{}
+		values := []string{}
+		for _, p := range ps {
+			v := value(p)
+			if !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+		return strings.Join(values, "; ")
+	}
+	m.Command = join(func(p Provenance) string { return p.Command })
+	m.Time = join(func(p Provenance) string { return p.Time })
+	m.Host = join(func(p Provenance) string { return p.Host })
+	return m, nil
+}
+
+// AddMerge appends the command and start time of this process to h,
+// the header of results it merged.
+func AddMerge(h *results.Header) {
+	p := CurrentProvenance()
+	h.Add("merge_command", p.Command)
+	h.Add("merge_time", p.Time)
+}
+
+// add appends the provenance to h.
+func (p Provenance) add(h *results.Header) {
+	h.Add("command", p.Command)
+	h.Add("time", p.Time)
+	h.Add("revision", p.Revision)
+	h.Add("hgt", p.HGT)
+	h.Add("go", p.Go)
+	h.Add("host", p.Host)
+}
+
+// Info is the typed content of an output header: the resolved parameters,
+// the provenance and the number of replicates the results are made of.
+// Keys added by a driver for its own outputs are kept in Header.
+type Info struct {
+	Config
+	Provenance
+	Replicates int
+	Header     *results.Header
+}
+
+// ReadInfo reads the header of an output file.
+func ReadInfo(filename string) (*Info, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h, err := results.ReadHeader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	info, err := ParseInfo(h)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return info, nil
+}
+
+// ParseInfo parses a header written by Config.Header.
+func ParseInfo(h *results.Header) (*Info, error) {
+	info := &Info{Header: h}
+	c, p := &info.Config, &info.Provenance
+	for _, key := range h.Keys() {
+		v, _ := h.Get(key)
+		var err error
+		switch key {
+		case "size":
+			c.Size, err = strconv.Atoi(v)
+		case "length":
+			c.Length, err = strconv.Atoi(v)
+		case "fragment":
+			c.Fragment, err = strconv.Atoi(v)
+		case "mutation":
+			c.Mutation, err = strconv.ParseFloat(v, 64)
+		case "transfer":
+			c.Transfer, err = strconv.ParseFloat(v, 64)
+		case "maxl":
+			c.MaxL, err = strconv.Atoi(v)
+		case "sample":
+			c.Sample, err = strconv.Atoi(v)
+		case "pairs":
+			c.Pairs = v
+		case "generations":
+			c.Gens, err = strconv.Atoi(v)
+		case "eqv":
+			c.EqvGens, err = strconv.Atoi(v)
+		case "repeats":
+			c.Reps, err = strconv.Atoi(v)
+		case "exptime":
+			c.ExpTime, err = strconv.ParseBool(v)
+		case "genome":
+			c.Linear = v == "linear"
+		case "window":
+			c.Window, err = strconv.Atoi(v)
+		case "step":
+			c.Step, err = strconv.Atoi(v)
+		case "seed":
+			c.Seed, err = strconv.ParseInt(v, 10, 64)
+		case "checkpoint":
+			c.Checkpoint, err = strconv.Atoi(v)
+		case "jobs":
+			c.Jobs, err = strconv.Atoi(v)
+		case "prefix":
+			c.Prefix = v
+		case "shard":
+			c.Shard = v
+		case "export":
+			c.Export = v
+		case "export_sample":
+			c.ExportSample, err = strconv.Atoi(v)
+		case "sweep.size":
+			err = c.Sweep.Size.Set(v)
+		case "sweep.length":
+			err = c.Sweep.Length.Set(v)
+		case "sweep.fragment":
+			err = c.Sweep.Fragment.Set(v)
+		case "sweep.mutation":
+			err = c.Sweep.Mutation.Set(v)
+		case "sweep.transfer":
+			err = c.Sweep.Transfer.Set(v)
+		case "sweep.mode":
+			c.Sweep.Mode = v
+		case "replicates":
+			info.Replicates, err = strconv.Atoi(v)
+		case "command":
+			p.Command = v
+		case "time":
+			p.Time = v
+		case "revision":
+			p.Revision = v
+		case "hgt":
+			p.HGT = v
+		case "go":
+			p.Go = v
+		case "host":
+			p.Host = v
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bad %s: %s", key, v)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/mingzhi/gomain/results"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	cfg := Config{Size: 100, Length: 1000, Fragment: 10, Mutation: 1e-4, Transfer: 2.5e-5,
+		MaxL: 50, Sample: 20, Pairs: "distinct", Gens: 100, EqvGens: 10, Reps: 8, ExpTime: true,
+		Linear: true, Window: 100, Step: 50, Seed: 7, Checkpoint: 2, Prefix: "out/x",
+		Export: "vcf", ExportSample: 5,
+		Sweep: Sweep{Size: Ints{10, 20}, Transfer: Floats{0, 1e-3}, Mode: "grid"}}
+	p := Provenance{Command: "gomain fwd hpc -prefix out/x", Time: "2020-01-02T03:04:05Z",
+		Revision: "abc+dirty", HGT: "v0.1.0", Go: "go1.20", Host: "node0"}
+
+	h := cfg.HeaderWith(p)
+	h.Add("replicates", 8)
+	var buf bytes.Buffer
+	if err := h.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	h2, err := results.ReadHeader(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := ParseInfo(h2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Config, cfg) {
+		t.Errorf("config = %+v, want %+v", info.Config, cfg)
+	}
+	if info.Provenance != p {
+		t.Errorf("provenance = %+v, want %+v", info.Provenance, p)
+	}
+	if info.Replicates != 8 {
+		t.Errorf("replicates = %d, want 8", info.Replicates)
+	}
+
+	bad := &results.Header{}
+	bad.Add("size", "many")
+	if _, err := ParseInfo(bad); err == nil || !strings.Contains(err.Error(), "size") {
+		t.Errorf("bad size: error %v", err)
+	}
+}
+
+func TestMergeProvenance(t *testing.T) {
+	part := func(host string) Provenance {
+		return Provenance{Command: "gomain fwd hpc -shard auto", Time: "t0",
+			Revision: "abc", HGT: "v0.1.0", Go: "go1.20", Host: host}
+	}
+	ps := []Provenance{part("node0"), part("node1"), part("node0")}
+	ps[2].Time = "t1"
+	m, err := MergeProvenance(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Provenance{Command: "gomain fwd hpc -shard auto", Time: "t0; t1",
+		Revision: "abc", HGT: "v0.1.0", Go: "go1.20", Host: "node0; node1"}
+	if m != want {
+		t.Errorf("merged %+v, want %+v", m, want)
+	}
+
+	changes := map[string]func(p *Provenance){
+		"revision": func(p *Provenance) { p.Revision = "def" },
+		"hgt":      func(p *Provenance) { p.HGT = "v0.2.0" },
+		"go":       func(p *Provenance) { p.Go = "go1.21" },
+	}
+	for key, change := range changes {
+		ps := []Provenance{part("node0"), part("node1")}
+		change(&ps[1])
+		_, err := MergeProvenance(ps)
+		if err == nil {
+			t.Errorf("parts with different %s merged without error", key)
+		} else if !strings.Contains(err.Error(), key) || !strings.Contains(err.Error(), "part 1") {
+			t.Errorf("different %s: error %q does not name it and the part", key, err)
+		}
+	}
+}
+
+func TestBuildVersions(t *testing.T) {
+	info := &debug.BuildInfo{
+		Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "abc"}, {Key: "vcs.modified", Value: "true"}},
+		Deps: []*debug.Module{
+			{Path: "github.com/mingzhi/gomath", Version: "v1.0.0"},
+			{Path: "github.com/mingzhi/hgt", Version: "v0.1.0", Replace: &debug.Module{Path: "../hgt"}},
+		},
+	}
+	rev, hgt := buildVersions(info, "unknown", "unknown")
+	if rev != "abc+dirty" || hgt != "../hgt" {
+		t.Errorf("versions = %s, %s, want abc+dirty, ../hgt", rev, hgt)
+	}
+	rev, hgt = buildVersions(&debug.BuildInfo{}, "unknown", "unknown")
+	if rev != "unknown" || hgt != "unknown" {
+		t.Errorf("versions without build info = %s, %s, want unknown", rev, hgt)
+	}
+
+	revision, hgtVersion = "def", "v0.2.0"
+	defer func() { revision, hgtVersion = "", "" }()
+	if p := CurrentProvenance(); p.Revision != "def" || p.HGT != "v0.2.0" {
+		t.Errorf("stamped versions = %s, %s, want def, v0.2.0", p.Revision, p.HGT)
+	}
+}
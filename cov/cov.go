@@ -0,0 +1,76 @@
+// Package cov computes the covariance series of the differences
+// between pairs of genomes, on a circular or a linear genome.
+package cov
+
+import (
+	"github.com/mingzhi/hgt/covs"
+	"sort"
+)
+
+// Series returns the scov, rcov, xy, xsys and smxy series up to distance
+// maxl: from CovCircle of cm for a circular genome, or from Linear for
+// a linear one.
+func Series(linear bool, cm *covs.CMatrix, diffmatrix [][]int, length, maxl int) (scovs, rcovs, xyPL, xsysPL, smXYPL []float64) {
+	if linear {
+		return Linear(diffmatrix, length, maxl)
+	}
+	return cm.CovCircle(maxl)
+}
+
+// Linear returns the covariance series of a linear genome of the given
+// length, from the sorted positions at which every pair differs.
+// Distances do not wrap around the end of the genome, so at distance l
+// the series are averaged over the length-l site pairs (k, k+l).
+// For a pair with difference indicators x, and means xs and ys of x(k)
+// and x(k+l) over those site pairs, averaged over the pairs:
+//
+//	xy   = mean of x(k)x(k+l)
+//	xsys = mean of xs*ys
+//	smxy = mean of xs * mean of ys
+//	scov = xy - xsys
+//	rcov = xsys - smxy
+func Linear(diffmatrix [][]int, length, maxl int) (scovs, rcovs, xyPL, xsysPL, smXYPL []float64) {
+	scovs = make([]float64, maxl)
+	rcovs = make([]float64, maxl)
+	xyPL = make([]float64, maxl)
+	xsysPL = make([]float64, maxl)
+	smXYPL = make([]float64, maxl)
+
+	n := float64(len(diffmatrix))
+	x := make([]bool, length)
+	xs := make([]float64, maxl) // sums over the pairs of xs
+	ys := make([]float64, maxl) // sums over the pairs of ys
+	for _, d := range diffmatrix {
+		for _, k := range d {
+			x[k] = true
+		}
+		for l := 0; l < maxl && l < length; l++ {
+			m := float64(length - l)
+			xy := 0
+			for _, k := range d {
+				if k+l < length && x[k+l] {
+					xy++
+				}
+			}
+			// differences in [0, length-l) and in [l, length)
+			a := float64(sort.SearchInts(d, length-l)) / m
+			b := float64(len(d)-sort.SearchInts(d, l)) / m
+			xyPL[l] += float64(xy) / m
+			xsysPL[l] += a * b
+			xs[l] += a
+			ys[l] += b
+		}
+		for _, k := range d {
+			x[k] = false
+		}
+	}
+
+	for l := 0; l < maxl; l++ {
+		xyPL[l] /= n
+		xsysPL[l] /= n
+		smXYPL[l] = xs[l] / n * ys[l] / n
+		scovs[l] = xyPL[l] - xsysPL[l]
+		rcovs[l] = xsysPL[l] - smXYPL[l]
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package cov
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-12
+}
+
+// naive returns the scov and rcov series of the differences of the pairs,
+// averaging x(k)x(k+l) and the means of x(k) and x(k+l) over the site
+// pairs (k, k+l) with k+l < length, or over all k with k+l taken modulo
+// length if circular.
+func naive(diffmatrix [][]int, length, maxl int, circular bool) (scovs, rcovs []float64) {
+	scovs = make([]float64, maxl)
+	rcovs = make([]float64, maxl)
+	n := float64(len(diffmatrix))
+	for l := 0; l < maxl; l++ {
+		var xy, xsys, xs, ys float64
+		for _, d := range diffmatrix {
+			x := make([]float64, length)
+			for _, k := range d {
+				x[k] = 1
+			}
+			m := length - l
+			if circular {
+				m = length
+			}
+			var sxy, sx, sy float64
+			for k := 0; k < m; k++ {
+				y := x[(k+l)%length]
+				sxy += x[k] * y
+				sx += x[k]
+				sy += y
+			}
+			fm := float64(m)
+			xy += sxy / fm
+			xsys += sx / fm * sy / fm
+			xs += sx / fm
+			ys += sy / fm
+		}
+		scovs[l] = xy/n - xsys/n
+		rcovs[l] = xsys/n - xs/n*ys/n
+	}
+	return
+}
+
+func TestLinearByHand(t *testing.T) {
+	// one pair differing at the two ends of a genome of 4 sites:
+	// x = 1 0 0 1
+	diffmatrix := [][]int{{0, 3}}
+	scovs, rcovs, xy, xsys, smxy := Linear(diffmatrix, 4, 4)
+
+	// at distance l the site pairs are (k, k+l) for k < 4-l
+	wantXY := []float64{2.0 / 4, 0, 0, 1}
+	wantXsYs := []float64{1.0 / 4, 1.0 / 9, 1.0 / 4, 1}
+	wantScov := []float64{1.0 / 4, -1.0 / 9, -1.0 / 4, 0}
+	for l := range wantXY {
+		if !closeTo(xy[l], wantXY[l]) || !closeTo(xsys[l], wantXsYs[l]) || !closeTo(smxy[l], wantXsYs[l]) {
+			t.Errorf("l = %d: xy, xsys, smxy = %g, %g, %g, want %g, %g, %g",
+				l, xy[l], xsys[l], smxy[l], wantXY[l], wantXsYs[l], wantXsYs[l])
+		}
+		if !closeTo(scovs[l], wantScov[l]) || !closeTo(rcovs[l], 0) {
+			t.Errorf("l = %d: scov, rcov = %g, %g, want %g, 0", l, scovs[l], rcovs[l], wantScov[l])
+		}
+	}
+
+	// On a circle the sites 3 and 0 are neighbours, so scov(1) is 1/4 - 1/4 = 0:
+	// only the linear normalisation by 4-l leaves the ends unpaired.
+	circular, _ := naive(diffmatrix, 4, 4, true)
+	if !closeTo(circular[1], 0) || closeTo(circular[1], scovs[1]) {
+		t.Errorf("circular scov(1) = %g, linear %g: want 0 and -1/9", circular[1], scovs[1])
+	}
+	if !closeTo(circular[0], scovs[0]) {
+		t.Errorf("scov(0) = %g, circular %g: want equal at distance 0", scovs[0], circular[0])
+	}
+}
+
+func TestLinearNaive(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	length, maxl := 37, 20
+	diffmatrix := make([][]int, 6)
+	for i := range diffmatrix {
+		for k := 0; k < length; k++ {
+			if r.Float64() < 0.3 {
+				diffmatrix[i] = append(diffmatrix[i], k)
+			}
+		}
+	}
+
+	scovs, rcovs, _, _, _ := Linear(diffmatrix, length, maxl)
+	wantScovs, wantRcovs := naive(diffmatrix, length, maxl, false)
+	for l := 0; l < maxl; l++ {
+		if !closeTo(scovs[l], wantScovs[l]) || !closeTo(rcovs[l], wantRcovs[l]) {
+			t.Errorf("l = %d: scov, rcov = %g, %g, want %g, %g", l, scovs[l], rcovs[l], wantScovs[l], wantRcovs[l])
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package empirical computes ks, vd and the covariance curves of a real
+// alignment, in the same way and the same files as the simulations.
+package empirical
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/cov"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/seqio"
+	"github.com/mingzhi/gomain/stats"
+	"github.com/mingzhi/gomain/window"
+	"github.com/mingzhi/hgt/covs"
+	"log"
+)
+
+// fourfold are the first two bases of the codons whose third position
+// is fourfold degenerate in the standard genetic code.
+var fourfold = map[string]bool{
+	"CT": true, // Leu
+	"GT": true, // Val
+	"TC": true, // Ser
+	"CC": true, // Pro
+	"AC": true, // Thr
+	"GC": true, // Ala
+	"CG": true, // Arg
+	"GG": true, // Gly
+}
+
+// Run reads an alignment and writes the _d.csv and _covs.csv files
+// of all the pairs of its sequences, and with a positive -window the
+// _scan.csv and _scan_covs.csv tracks of its sliding windows.
+// The genome length and the sample are those of the alignment.
+func Run(args []string) {
+	cfg := config.Config{
+		MaxL:   100,
+		Pairs:  "all",
+		Prefix: "empirical",
+	}
+
+	fs := flag.NewFlagSet("empirical", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	cfg.RegisterLinearFlag(fs)
+	cfg.RegisterWindowFlag(fs)
+	cfg.RegisterStepFlag(fs)
+	sites := fs.String("sites", "all", "sites to use: all, 1, 2 or 3 (codon position), or 4fold")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gomain empirical [flags] <alignment.fasta|alignment.phy>")
+		fs.PrintDefaults()
+	}
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		log.Fatal("no alignment given")
+	}
+	filename := fs.Arg(0)
+
+	names, seqs, err := seqio.ReadAlignment(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(seqs) < 2 {
+		log.Fatalf("%s: fewer than two sequences", filename)
+	}
+
+	columns, err := mask(seqs, *sites)
+	if err != nil {
+		log.Fatal(err)
+	}
+	length := len(columns)
+	cfg.Length = length
+	cfg.Sample = len(seqs)
+	if cfg.MaxL > length {
+		log.Fatalf("maxl %d is larger than the %d sites used", cfg.MaxL, length)
+	}
+	if cfg.Window > 0 && (cfg.Window > length || cfg.MaxL > cfg.Window) {
+		log.Fatalf("window %d must be at most the %d sites used and at least maxl", cfg.Window, length)
+	}
+
+	// differences of every pair, at the positions of the masked alignment
+	masked := make([][]byte, len(seqs))
+	for j, s := range seqs {
+		masked[j] = make([]byte, length)
+		for h, c := range columns {
+			masked[j][h] = s[c]
+		}
+	}
+	diffmatrix := bitseq.DiffMatrix(masked, bitseq.AllPairs(len(seqs)), 0)
+
+	cmatrix := covs.NewCMatrix(len(diffmatrix), length, diffmatrix)
+	ks, vd := cmatrix.D()
+	moments := stats.NewCovs(cfg.MaxL)
+	moments.Increment(cov.Series(cfg.Linear, cmatrix, diffmatrix, length, cfg.MaxL))
+
+	h := cfg.Header()
+	h.Add("alignment", filename)
+	h.Add("sequences", len(names))
+	h.Add("alignment_length", len(seqs[0]))
+	h.Add("sites", *sites)
+	h.Add("replicates", 1)
+
+	dfile, err := results.CreateD(cfg.Prefix+"_d.csv", h)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dfile.Close()
+	if err := results.WriteD(dfile, ks, vd); err != nil {
+		log.Fatal(err)
+	}
+	if err := results.WriteCovs(cfg.Prefix+"_covs.csv", h, moments); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d sequences, %d of %d sites: ks = %g, vd = %g\n", len(seqs), length, len(seqs[0]), ks, vd)
+
+	if cfg.Window > 0 {
+		tracks := window.Scan(len(diffmatrix), diffmatrix, window.Sliding(length, cfg.Window, cfg.Step), cfg.MaxL, 0)
+		// report the windows of sites used in the columns of the alignment
+		for i := range tracks {
+			t := &tracks[i]
+			t.Start, t.End = columns[t.Start], columns[t.End-1]+1
+		}
+		if err := window.WriteScan(cfg.Prefix, h, tracks); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// mask returns the columns of the alignment selected by sites,
+// leaving out those with a gap or an ambiguous base in any sequence.
+func mask(seqs [][]byte, sites string) ([]int, error) {
+	keep := func(c int) bool { return true }
+	switch sites {
+	case "all":
+	case "1", "2", "3":
+		pos := int(sites[0] - '1')
+		keep = func(c int) bool { return c%3 == pos }
+	case "4fold":
+		keep = func(c int) bool {
+			if c%3 != 2 {
+				return false
+			}
+			for _, s := range seqs {
+				if !fourfold[string(s[c-2:c])] {
+					return false
+				}
+			}
+			return true
+		}
+	default:
+		return nil, fmt.Errorf("unknown sites: %s", sites)
+	}
+
+	columns := []int{}
+	for c := range seqs[0] {
+		if !keep(c) {
+			continue
+		}
+		clean := true
+		for _, s := range seqs {
+			switch s[c] {
+			case 'A', 'C', 'G', 'T':
+			default:
+				clean = false
+			}
+		}
+		if clean {
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
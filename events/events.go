@@ -0,0 +1,123 @@
+// Package events streams the progress of a run as JSON Lines, one event
+// per replicate or sampled generation, so that a running job can be tailed
+// by a dashboard or a notebook.
+package events
+
+import (
+	"encoding/json"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is one line of the stream.
+type Event struct {
+	Type       string             `json:"type"` // start, replicate, generation or done
+	Command    string             `json:"command,omitempty"`
+	Replicate  int                `json:"replicate"`
+	Generation int                `json:"generation,omitempty"`
+	KS         *float64           `json:"ks,omitempty"`
+	VD         *float64           `json:"vd,omitempty"`
+	Elapsed    float64            `json:"elapsed"` // seconds since the start of the stream
+	Params     columnar.Params    `json:"params"`
+	Config     *config.Config     `json:"config,omitempty"`
+	Provenance *config.Provenance `json:"provenance,omitempty"`
+}
+
+// Stream writes events to a file or to stdout.
+type Stream struct {
+	mu      sync.Mutex
+	w       io.Writer
+	f       *os.File
+	enc     *json.Encoder
+	command string
+	params  columnar.Params
+	t0      time.Time
+}
+
+// Start opens the stream of cfg.Events, "-" for stdout, and writes the
+// start event of command with the resolved config and the provenance.
+// A resumed run appends to the file of the run it continues, so the
+// replicates after the last checkpoint are streamed again after its
+// second start event. It returns nil if no stream is set.
+func Start(command string, cfg *config.Config) (*Stream, error) {
+	if cfg.Events == "" {
+		return nil, nil
+	}
+	s := &Stream{command: command, params: columnar.ParamsOf(cfg), t0: time.Now()}
+	if cfg.Events == "-" {
+		s.w = os.Stdout
+	} else {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if cfg.Resume {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		f, err := os.OpenFile(cfg.Events, flags, 0644)
+		if err != nil {
+			return nil, err
+		}
+		s.w, s.f = f, f
+	}
+	s.enc = json.NewEncoder(s.w)
+
+	p := config.CurrentProvenance()
+	err := s.emit(Event{Type: "start", Command: command, Replicate: -1, Params: s.params, Config: cfg, Provenance: &p})
+	if err != nil {
+		if s.f != nil {
+			s.f.Close()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// number returns a pointer to x, or nil if x is not a finite number,
+// which JSON cannot encode.
+func number(x float64) *float64 {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return nil
+	}
+	return &x
+}
+
+func (s *Stream) emit(e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Elapsed = time.Since(s.t0).Seconds()
+	return s.enc.Encode(&e)
+}
+
+// Replicate writes the ks and vd of replicate i.
+func (s *Stream) Replicate(i int, ks, vd float64) error {
+	if s == nil {
+		return nil
+	}
+	return s.emit(Event{Type: "replicate", Replicate: i, KS: number(ks), VD: number(vd), Params: s.params})
+}
+
+// Generation writes the ks and vd of replicate i at a generation.
+// p are the parameters of the replicate.
+func (s *Stream) Generation(p columnar.Params, i, generation int, ks, vd float64) error {
+	if s == nil {
+		return nil
+	}
+	return s.emit(Event{Type: "generation", Replicate: i, Generation: generation, KS: number(ks), VD: number(vd), Params: p})
+}
+
+// Close writes the done event and closes the stream.
+func (s *Stream) Close() error {
+	if s == nil {
+		return nil
+	}
+	err := s.emit(Event{Type: "done", Command: s.command, Replicate: -1, Params: s.params})
+	if s.f != nil {
+		if cerr := s.f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
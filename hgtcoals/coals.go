@@ -1,181 +1,141 @@
-package main
+package hgtcoals
 
 import (
 	"flag"
-	"fmt"
-	"github.com/mingzhi/gomath/stat/desc"
-	"github.com/mingzhi/hgt/coals"
-	"github.com/mingzhi/hgt/covs"
-	"math"
-	"os"
-	"runtime"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/events"
+	"github.com/mingzhi/gomain/registry"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/stats"
+	"log"
 	"time"
 )
 
-var (
-	size     int     // population size
-	sample   int     // sample size
-	length   int     // genome length
-	repeats  int     // number of repeats
-	maxl     int     // max l
-	mutation float64 // mutation rate
-	transfer float64 // transfer rate
-	fragment int     // transfer fragment
-	prefix   string  // prefix
-)
+// defaultConfig returns the default parameters of the coalescent drivers.
+func defaultConfig() config.Config {
+	return config.Config{
+		Size:     1000000,
+		Sample:   2,
+		Pairs:    "all", // of the sampled genomes
+		Length:   10000,
+		Fragment: 100,
+		Reps:     1000,
+		MaxL:     100,
+		Transfer: 1e-6,
+		Mutation: 1e-8,
+	}
+}
 
-func init() {
-	flag.IntVar(&size, "size", 1000000, "population size")
-	flag.IntVar(&sample, "sample", 2, "sample size")
-	flag.IntVar(&length, "genome", 10000, "genome length")
-	flag.IntVar(&fragment, "frag", 100, "fragment length (ratio)")
-	flag.IntVar(&repeats, "rep", 1000, "repeats")
-	flag.IntVar(&maxl, "maxl", 100, "maxl")
-	flag.Float64Var(&transfer, "transfer", 1e-6, "transfer rate")
-	flag.Float64Var(&mutation, "mutation", 1e-8, "mutation rate")
-	flag.StringVar(&prefix, "prefix", "", "prefix")
+// parseFlags parses the coalescent driver flags in args into cfg.
+// The parallel driver also takes -jobs.
+func parseFlags(name string, parallel bool, cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "sample size")
+	fs.IntVar(&cfg.Reps, "rep", cfg.Reps, "repeats")
+	if parallel {
+		cfg.RegisterJobsFlag(fs)
+	}
+	cfg.RegisterShardFlag(fs)
+	cfg.RegisterLinearFlag(fs)
+	cfg.RegisterParquetFlag(fs)
+	cfg.RegisterDBFlag(fs)
+	cfg.RegisterEventsFlag(fs)
+	cfg.RegisterExportFlags(fs)
+
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	cfg.AdjustMaxL()
+}
 
-	flag.Parse()
-	if maxl < 2*fragment {
-		maxl = 2 * fragment
+// writeShard writes the moments of a shard for merging.
+func writeShard(cfg *config.Config, moments *stats.Covs) {
+	if !cfg.Sharded() {
+		return
+	}
+	h := cfg.Header()
+	h.Add("replicates", moments.N())
+	if err := results.WriteMoments(cfg.Out("_moments.csv"), h, moments); err != nil {
+		log.Panic(err)
 	}
 }
 
-func main() {
-	means := make([][]*desc.Mean, 5)
-	sds := make([][]*desc.StandardDeviation, 5)
-	for i := 0; i < 5; i++ {
-		means[i] = make([]*desc.Mean, maxl)
-		sds[i] = make([]*desc.StandardDeviation, maxl)
-		for j := 0; j < maxl; j++ {
-			means[i][j] = desc.NewMean()
-			sds[i][j] = desc.NewStandardDeviationWithBiasCorrection()
+// save writes the replicates from b on and the covariance curves
+// to a Parquet file and records them in the run database, if asked for.
+func save(cfg *config.Config, run *registry.Run, b int, moments *stats.Covs) {
+	index := func(i int) (int, int) {
+		return b + i, -1
+	}
+	if cfg.Parquet {
+		if err := columnar.Save(cfg.Out(".parquet"), cfg, cfg.Out("_d.csv"), index, moments); err != nil {
+			log.Panic(err)
 		}
 	}
+	if err := run.Save(cfg, cfg.Out("_d.csv"), index, moments); err != nil {
+		log.Panic(err)
+	}
+}
 
-	dfile, err := os.Create(prefix + "_d.csv")
+// Run simulates the coalescent replicates one after another.
+func Run(args []string) {
+	cfg := defaultConfig()
+	parseFlags("coals", false, &cfg, args)
+	run, err := registry.Start("coals", &cfg)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+	ev, err := events.Start("coals", &cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
-	defer dfile.Close()
 
-	dfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-	dfile.WriteString(fmt.Sprintf("#sample: %d\n", sample))
-	dfile.WriteString(fmt.Sprintf("#length: %d\n", length))
-	dfile.WriteString(fmt.Sprintf("#fragment: %d\n", fragment))
-	dfile.WriteString(fmt.Sprintf("#repeats: %d\n", repeats))
-	dfile.WriteString(fmt.Sprintf("#maxl: %d\n", maxl))
-	dfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-	dfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-	dfile.WriteString("#ks, vd\n")
+	// replicates of this shard
+	b, e := cfg.ShardRange(cfg.Reps)
+	repeats := e - b
+	step := results.ProgressStep(repeats)
 
-	runtime.GOMAXPROCS(runtime.NumCPU())
+	moments := stats.NewCovs(cfg.MaxL)
+
+	dfile, err := results.CreateD(cfg.Out("_d.csv"), cfg.Header())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dfile.Close()
 
 	t0 := time.Now()
-	for c := 0; c < repeats; c++ {
-		w := coals.NewWFPopulation(size, sample, length, mutation, transfer, fragment)
-		w.Seed(c)
-		w.Backtrace()
-		seqs := w.Fortrace()
-		diffmatrix := [][]int{}
-		for i := 0; i < sample; i++ {
-			for j := i + 1; j < sample; j++ {
-				diff := []int{}
-				for k := 0; k < length; k++ {
-					if seqs[i][k] != seqs[j][k] {
-						diff = append(diff, k)
-					}
-				}
-				diffmatrix = append(diffmatrix, diff)
-			}
+	for c := b; c < e; c++ {
+		r := simulate(&cfg, c, 0)
+		if err := results.WriteD(dfile, r.ks, r.vd); err != nil {
+			log.Panic(err)
 		}
-
-		cmatrix := covs.NewCMatrix(len(diffmatrix), length, diffmatrix)
-		ks, vd := cmatrix.D()
-		dfile.WriteString(fmt.Sprintf("%g,%g\n", ks, vd))
-
-		scovs, rcovs, xyPL, xsysPL, smXYPL := cmatrix.CovCircle(maxl)
-		for l := 0; l < maxl; l++ {
-			means[0][l].Increment(scovs[l])
-			means[1][l].Increment(rcovs[l])
-			means[2][l].Increment(xyPL[l])
-			means[3][l].Increment(xsysPL[l])
-			means[4][l].Increment(smXYPL[l])
-
-			sds[0][l].Increment(scovs[l])
-			sds[1][l].Increment(rcovs[l])
-			sds[2][l].Increment(xyPL[l])
-			sds[3][l].Increment(xsysPL[l])
-			sds[4][l].Increment(smXYPL[l])
+		if err := ev.Replicate(c, r.ks, r.vd); err != nil {
+			log.Panic(err)
 		}
+		moments.Increment(r.scovs, r.rcovs, r.xyPL, r.xsysPL, r.smXYPL)
 
-		if (c+1)%(repeats/100) == 0 {
+		if n := c + 1 - b; n%step == 0 {
 			t1 := time.Now()
-			fmt.Printf("%d%%,%v\n", (c+1)/(repeats/100), t1.Sub(t0))
-			covfile, err := os.Create(prefix + "_covs.csv")
+			log.Printf("%d%%,%v\n", n*100/repeats, t1.Sub(t0))
+			h := cfg.Header()
+			h.Add("replicates", n)
+			err := results.WriteCovs(cfg.Out("_covs.csv"), h, moments)
 			if err != nil {
-				fmt.Println(err)
+				log.Panic(err)
 			}
-
-			covfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-			covfile.WriteString(fmt.Sprintf("#sample: %d\n", sample))
-			covfile.WriteString(fmt.Sprintf("#length: %d\n", length))
-			covfile.WriteString(fmt.Sprintf("#fragment: %d\n", fragment))
-			covfile.WriteString(fmt.Sprintf("#repeats: %d\n", repeats))
-			covfile.WriteString(fmt.Sprintf("#maxl: %d\n", maxl))
-			covfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-			covfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-			covfile.WriteString("#dist, scov, rcov, xy, xsys, smxy, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n")
-
-			for i := 0; i < maxl; i++ {
-				covfile.WriteString(fmt.Sprintf("%d,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g\n",
-					i,
-					means[0][i].GetResult(),
-					means[1][i].GetResult(),
-					means[2][i].GetResult(),
-					means[3][i].GetResult(),
-					means[4][i].GetResult(),
-					sds[0][i].GetResult()/math.Sqrt(float64(repeats)),
-					sds[1][i].GetResult()/math.Sqrt(float64(repeats)),
-					sds[2][i].GetResult()/math.Sqrt(float64(repeats)),
-					sds[3][i].GetResult()/math.Sqrt(float64(repeats)),
-					sds[4][i].GetResult()/math.Sqrt(float64(repeats)),
-				))
-			}
-
-			covfile.Close()
 		}
 	}
 
-	covfile, err := os.Create(prefix + "_covs.csv")
+	h := cfg.Header()
+	h.Add("replicates", repeats)
+	err = results.WriteCovs(cfg.Out("_covs.csv"), h, moments)
 	if err != nil {
-		fmt.Println(err)
+		log.Panic(err)
 	}
-	defer covfile.Close()
-
-	covfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-	covfile.WriteString(fmt.Sprintf("#sample: %d\n", sample))
-	covfile.WriteString(fmt.Sprintf("#length: %d\n", length))
-	covfile.WriteString(fmt.Sprintf("#fragment: %d\n", fragment))
-	covfile.WriteString(fmt.Sprintf("#repeats: %d\n", repeats))
-	covfile.WriteString(fmt.Sprintf("#maxl: %d\n", maxl))
-	covfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-	covfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-	covfile.WriteString("#dist, scov, rcov, xy, xsys, smxy, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n")
-
-	for i := 0; i < maxl; i++ {
-		covfile.WriteString(fmt.Sprintf("%d,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g\n",
-			i,
-			means[0][i].GetResult(),
-			means[1][i].GetResult(),
-			means[2][i].GetResult(),
-			means[3][i].GetResult(),
-			means[4][i].GetResult(),
-			sds[0][i].GetResult()/math.Sqrt(float64(repeats)),
-			sds[1][i].GetResult()/math.Sqrt(float64(repeats)),
-			sds[2][i].GetResult()/math.Sqrt(float64(repeats)),
-			sds[3][i].GetResult()/math.Sqrt(float64(repeats)),
-			sds[4][i].GetResult()/math.Sqrt(float64(repeats)),
-		))
+	writeShard(&cfg, moments)
+	save(&cfg, run, b, moments)
+	if err := ev.Close(); err != nil {
+		log.Panic(err)
 	}
 }
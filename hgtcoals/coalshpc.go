@@ -1,184 +1,125 @@
-package main
+package hgtcoals
 
 import (
-	"flag"
 	"fmt"
-	"github.com/mingzhi/gomath/stat/desc"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/cov"
+	"github.com/mingzhi/gomain/events"
+	"github.com/mingzhi/gomain/registry"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/rng"
+	"github.com/mingzhi/gomain/sched"
+	"github.com/mingzhi/gomain/seqio"
+	"github.com/mingzhi/gomain/stats"
 	"github.com/mingzhi/hgt/coals"
 	"github.com/mingzhi/hgt/covs"
-	"os"
-	"runtime"
+	"log"
 	"time"
 )
 
-var (
-	size     int     // population size
-	sample   int     // sample size
-	length   int     // genome length
-	repeats  int     // number of repeats
-	maxl     int     // max l
-	mutation float64 // mutation rate
-	transfer float64 // transfer rate
-	fragment int     // transfer fragment
-	prefix   string  // prefix
-)
-
-type Moments struct {
-	Mean *desc.Mean
-	Sd   *desc.StandardDeviation
-}
-
-func (m *Moments) Increment(d float64) {
-	m.Mean.Increment(d)
-	m.Sd.Increment(d)
-}
-
 type Results struct {
+	index                              int // replicate index
 	ks, vd                             float64
 	scovs, rcovs, xyPL, xsysPL, smXYPL []float64
 }
 
-func init() {
-	flag.IntVar(&size, "size", 1000000, "population size")
-	flag.IntVar(&sample, "sample", 2, "sample size")
-	flag.IntVar(&length, "genome", 10000, "genome length")
-	flag.IntVar(&fragment, "frag", 100, "fragment length (ratio)")
-	flag.IntVar(&repeats, "rep", 1000, "repeats")
-	flag.IntVar(&maxl, "maxl", 100, "maxl")
-	flag.Float64Var(&transfer, "transfer", 1e-6, "transfer rate")
-	flag.Float64Var(&mutation, "mutation", 1e-8, "mutation rate")
-	flag.StringVar(&prefix, "prefix", "", "prefix")
-
-	flag.Parse()
-	if maxl < 2*fragment {
-		maxl = 2 * fragment
-	}
-}
-
-func main() {
-	ncpu := runtime.NumCPU()
-	runtime.GOMAXPROCS(ncpu)
-	ch := make(chan Results, ncpu)
+// HPC simulates the coalescent replicates on all CPUs.
+func HPC(args []string) {
+	cfg := defaultConfig()
+	parseFlags("coals hpc", true, &cfg, args)
 
 	t0 := time.Now()
 
-	for i := 0; i < ncpu; i++ {
-		begin := i * repeats / ncpu
-		end := (i + 1) * repeats / ncpu
-		go simusome(begin, end, ch)
+	run, err := registry.Start("coals hpc", &cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	analysis(ch)
+	ev, err := events.Start("coals hpc", &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	analysis(&cfg, run, ev)
 
 	t1 := time.Now()
-	fmt.Println(t1.Sub(t0))
+	log.Println(t1.Sub(t0))
 }
 
-func simusome(begin, end int, ch chan Results) {
-	for i := begin; i < end; i++ {
-		w := coals.NewWFPopulation(size, sample, length, mutation, transfer, fragment)
-		w.Seed(i)
-		w.Backtrace()
-		seqs := w.Fortrace()
-		diffmatrix := [][]int{}
-		sample := w.SampleSize
-		for j := 0; j < sample; j++ {
-			for k := j + 1; k < sample; k++ {
-				diff := []int{}
-				for h := 0; h < length; h++ {
-					if seqs[j][h] != seqs[k][h] {
-						diff = append(diff, h)
-					}
-				}
-				diffmatrix = append(diffmatrix, diff)
-			}
-		}
-
-		cmatrix := covs.NewCMatrix(len(diffmatrix), length, diffmatrix)
-		ks, vd := cmatrix.D()
-		scovs, rcovs, xyPL, xsysPL, smXYPL := cmatrix.CovCircle(maxl)
-		results := Results{
-			ks:     ks,
-			vd:     vd,
-			scovs:  scovs,
-			rcovs:  rcovs,
-			xyPL:   xyPL,
-			xsysPL: xsysPL,
-			smXYPL: smXYPL,
-		}
-		ch <- results
+// simulate simulates replicate i, diffing the sampled genomes
+// on jobs goroutines.
+func simulate(cfg *config.Config, i, jobs int) Results {
+	length := cfg.Length
+	w := coals.NewWFPopulation(cfg.Size, cfg.Sample, length, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	w.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, i)))
+	w.Backtrace()
+	seqs := w.Fortrace()
+	if err := seqio.Save(cfg, fmt.Sprintf("%s_rep%d", cfg.Prefix, i), i, seqs); err != nil {
+		log.Panic(err)
+	}
+	diffmatrix := bitseq.DiffMatrix(seqs, bitseq.AllPairs(w.SampleSize), jobs)
+
+	cmatrix := covs.NewCMatrix(len(diffmatrix), length, diffmatrix)
+	ks, vd := cmatrix.D()
+	scovs, rcovs, xyPL, xsysPL, smXYPL := cov.Series(cfg.Linear, cmatrix, diffmatrix, length, cfg.MaxL)
+	r := Results{
+		index:  i,
+		ks:     ks,
+		vd:     vd,
+		scovs:  scovs,
+		rcovs:  rcovs,
+		xyPL:   xyPL,
+		xsysPL: xsysPL,
+		smXYPL: smXYPL,
 	}
+	return r
 }
 
-func analysis(ch chan Results) {
-	dfile, err := os.Create(fmt.Sprintf("%s_d.csv", prefix))
+// analysis simulates the replicates and collects them in replicate order.
+func analysis(cfg *config.Config, run *registry.Run, ev *events.Stream) {
+	dfile, err := results.CreateD(cfg.Out("_d.csv"), cfg.Header())
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 	defer dfile.Close()
 
-	dfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-	dfile.WriteString(fmt.Sprintf("#length: %d\n", length))
-	dfile.WriteString(fmt.Sprintf("#fragment: %d\n", fragment))
-	dfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-	dfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-	dfile.WriteString(fmt.Sprintf("#sample: %d\n", sample))
-	dfile.WriteString("#ks, vd\n")
-
-	momentArr := make([][]Moments, 5)
-	for i := 0; i < len(momentArr); i++ {
-		for j := 0; j < maxl; j++ {
-			moments := Moments{
-				Mean: desc.NewMean(),
-				Sd:   desc.NewStandardDeviationWithBiasCorrection(),
-			}
-			momentArr[i] = append(momentArr[i], moments)
-		}
-	}
+	moments := stats.NewCovs(cfg.MaxL)
 
-	for i := 0; i < repeats; i++ {
-		results := <-ch
-		dfile.WriteString(fmt.Sprintf("%g,%g\n", results.ks, results.vd))
-		for j := 0; j < maxl; j++ {
-			momentArr[0][j].Increment(results.scovs[j])
-			momentArr[1][j].Increment(results.rcovs[j])
-			momentArr[2][j].Increment(results.xyPL[j])
-			momentArr[3][j].Increment(results.xsysPL[j])
-			momentArr[4][j].Increment(results.smXYPL[j])
+	// replicates of this shard
+	b, e := cfg.ShardRange(cfg.Reps)
+	repeats := e - b
+	step := results.ProgressStep(repeats)
+
+	err = sched.RunOrdered(b, e, cfg.Jobs, func(i int) interface{} {
+		return simulate(cfg, i, 1)
+	}, func(i int, v interface{}) error {
+		r := v.(Results)
+		if err := results.WriteD(dfile, r.ks, r.vd); err != nil {
+			return err
+		}
+		if err := ev.Replicate(i, r.ks, r.vd); err != nil {
+			return err
 		}
+		moments.Increment(r.scovs, r.rcovs, r.xyPL, r.xsysPL, r.smXYPL)
 
-		if (i+1)%(repeats/100) == 0 {
-			cfile, err := os.Create(fmt.Sprintf("%s_covs.csv", prefix))
+		if n := i + 1 - b; n%step == 0 {
+			h := cfg.Header()
+			h.Add("replicates", n)
+			err := results.WriteCovs(cfg.Out("_covs.csv"), h, moments)
 			if err != nil {
-				panic(err)
-			}
-
-			cfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-			cfile.WriteString(fmt.Sprintf("#length: %d\n", length))
-			cfile.WriteString(fmt.Sprintf("#fragment: %d\n", fragment))
-			cfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-			cfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-			cfile.WriteString(fmt.Sprintf("#sample: %d\n", sample))
-			cfile.WriteString(fmt.Sprintf("#replicates: %d\n", i+1))
-			cfile.WriteString("#dist, scov, rcov, xy, xsys, smxy_sd, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n")
-
-			for j := 0; j < maxl; j++ {
-				cfile.WriteString(fmt.Sprintf("%d,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g\n",
-					j,
-					momentArr[0][j].Mean.GetResult(),
-					momentArr[1][j].Mean.GetResult(),
-					momentArr[2][j].Mean.GetResult(),
-					momentArr[3][j].Mean.GetResult(),
-					momentArr[4][j].Mean.GetResult(),
-					momentArr[0][j].Sd.GetResult(),
-					momentArr[1][j].Sd.GetResult(),
-					momentArr[2][j].Sd.GetResult(),
-					momentArr[3][j].Sd.GetResult(),
-					momentArr[4][j].Sd.GetResult(),
-				))
+				return err
 			}
-			cfile.Close()
 		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
 
+	writeShard(cfg, moments)
+	save(cfg, run, b, moments)
+	if err := ev.Close(); err != nil {
+		log.Panic(err)
 	}
 }
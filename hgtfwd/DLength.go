@@ -1,125 +1,129 @@
 // in this script, I want to determine the impact of genome length.
-package main
+package hgtfwd
 
 import (
-	"fmt"
-	covs "github.com/mingzhi/hgt/covs"
-	fwd "github.com/mingzhi/hgt/fwd3"
+	"flag"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/events"
+	"github.com/mingzhi/gomain/rng"
+	"github.com/mingzhi/gomain/window"
+	"github.com/mingzhi/hgt/fwd"
 	"log"
-	"math/rand"
 	"os"
 	"time"
 )
 
-func main() {
+// SweepLength records ks and vd over time for several prefix lengths
+// of the same genomes, or for the non-overlapping windows of -window
+// sites along them, in one table indexed by window.
+func SweepLength(args []string) {
+	cfg := sweepConfig()
+	cfg.Length = 100000
+	cfg.Prefix = "D"
+	cfg.Sweep.Length = config.Ints{100, 1000, 10000, 100000}
+
+	fs := flag.NewFlagSet("sweep length", flag.ExitOnError)
+	registerSweepFlags(fs, &cfg)
+	fs.Var(&cfg.Sweep.Length, "lengths", "prefix lengths of the genome to sweep over")
+	cfg.RegisterWindowFlag(fs)
+	cfg.RegisterEventsFlag(fs)
+	cfg.RegisterParquetFlag(fs)
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	windows := window.Prefixes(cfg.Sweep.Length)
+	if cfg.Window > 0 {
+		cfg.Sweep.Length = nil
+		windows = window.Tiles(cfg.Length, cfg.Window)
+	}
+	for _, w := range windows {
+		if w.End > cfg.Length {
+			log.Fatalf("window %d-%d is longer than the genome", w.Start, w.End)
+		}
+	}
+
+	ev, err := events.Start("sweep length", &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params := columnar.ParamsOf(&cfg)
+
 	t0 := time.Now() // start time
 	log.Printf("Start up at: %v\n", t0)
 
-	// population paramters
-	size := 1000       // population size = 1000
-	length := 100000   // genome length = 100000
-	mutation := 0.0001 // mutation rate = 1e-4
-	transfer := 0.0    // transfer rate = 0
-	fragment := 0      // transferred length = 0
-
 	// construct a population
-	pop := fwd.NewSeqPop(size, length, mutation, transfer, fragment)
+	pop := fwd.NewSeqPop(cfg.Size, cfg.Length, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	pop.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, 0)))
+	r := rng.New(cfg.Seed, rng.Sampling, 0)
 	// simulation parameters
-	samplesize := 100
-	numofgen := 100000
+	sampler := newSampler(&cfg)
+	numofgen := cfg.Gens
 
-	// create files
-	// file for length 100
-	file1, err := os.Create("D_length_100.csv")
+	f, err := os.Create(cfg.Prefix + "_windows.csv")
 	if err != nil {
 		panic(err)
 	}
-	defer file1.Close()
-	// file for length 1000
-	file2, err := os.Create("D_length_1000.csv")
-	if err != nil {
-		panic(err)
+	defer f.Close()
+	if err := cfg.Header().Write(f); err != nil {
+		log.Fatal(err)
 	}
-	defer file2.Close()
-	// file for length 10000
-	file3, err := os.Create("D_length_10000.csv")
-	if err != nil {
-		panic(err)
+	if err := window.WriteHeader(f); err != nil {
+		log.Fatal(err)
 	}
-	defer file3.Close()
-	// file for length 100000
-	file4, err := os.Create("D_length_100000.csv")
-	if err != nil {
-		panic(err)
+
+	var pw *columnar.Writer
+	if cfg.Parquet {
+		pw, err = columnar.Create(cfg.Prefix+".parquet", cfg.Header())
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
-	defer file4.Close()
 
 	// do the simulation
 	for i := 0; i < numofgen; i++ {
 		pop.Evolve()
+		generation := i + 1
 
-		// get #{samplesize} samples
-		rSeq := rand.Perm(pop.Size) // get a permutation sequence
-		samples := make([]fwd.Sequence, samplesize)
-		for j := 0; j < samplesize; j++ {
-			samples[j] = pop.Genomes[rSeq[j]]
+		drawn, err := sampler.Pairs(cfg.Size, r)
+		if err != nil {
+			log.Panic(err)
 		}
+		dmatrix := bitseq.DiffMatrix(genomes(pop), drawn, 0)
 
-		// calculate the distance matrix for different lengthes
-		dmatrix1 := [][]int{} // for length 100
-		dmatrix2 := [][]int{} // for length 1000
-		dmatrix3 := [][]int{} // for length 10000
-		dmatrix4 := [][]int{} // for length 100000
-		for j := 0; j < samplesize; j++ {
-			for k := j + 1; k < samplesize; k++ {
-				a := samples[j]
-				b := samples[k]
-				ds1 := []int{} // for length 100
-				ds2 := []int{} // for length 1000
-				ds3 := []int{} // for length 10000
-				ds4 := []int{} // for length 100000
-				for l := 0; l < len(a); l++ {
-					if a[l] != b[l] {
-						if l < 100 { // for length 100
-							ds1 = append(ds1, l)
-						}
-						if l < 1000 { // for length 1000
-							ds2 = append(ds2, l)
-						}
-						if l < 10000 { // for length 10000
-							ds3 = append(ds3, l)
-						}
-						ds4 = append(ds4, l)
-					}
-
-				}
-				dmatrix1 = append(dmatrix1, ds1)
-				dmatrix2 = append(dmatrix2, ds2)
-				dmatrix3 = append(dmatrix3, ds3)
-				dmatrix4 = append(dmatrix4, ds4)
+		// ks and vd of every window from the same diffs
+		stats := window.D(len(dmatrix), dmatrix, windows)
+		if err := window.WriteD(f, generation, stats); err != nil {
+			log.Panic(err)
+		}
+		if pw != nil {
+			if err := pw.Windows(params, 0, generation, stats); err != nil {
+				log.Panic(err)
+			}
+		}
+		for _, s := range stats {
+			params.Length = s.Length()
+			if err := ev.Generation(params, 0, generation, s.KS, s.VD); err != nil {
+				log.Panic(err)
 			}
 		}
-		// create cmatrix
-		cmatrix1 := covs.NewCMatrix(samplesize, 100, dmatrix1)
-		cmatrix2 := covs.NewCMatrix(samplesize, 1000, dmatrix2)
-		cmatrix3 := covs.NewCMatrix(samplesize, 10000, dmatrix3)
-		cmatrix4 := covs.NewCMatrix(samplesize, 100000, dmatrix4)
-		// calculate ks and vard
-		ks1, vd1 := cmatrix1.D()
-		ks2, vd2 := cmatrix2.D()
-		ks3, vd3 := cmatrix3.D()
-		ks4, vd4 := cmatrix4.D()
-		// write to file
-		file1.WriteString(fmt.Sprintf("%d,%g,%g\n", pop.NumOfGen, ks1, vd1))
-		file2.WriteString(fmt.Sprintf("%d,%g,%g\n", pop.NumOfGen, ks2, vd2))
-		file3.WriteString(fmt.Sprintf("%d,%g,%g\n", pop.NumOfGen, ks3, vd3))
-		file4.WriteString(fmt.Sprintf("%d,%g,%g\n", pop.NumOfGen, ks4, vd4))
 
 		// printing the process
-		if pop.NumOfGen%1000 == 0 {
-			fmt.Printf("Number of Generation: %d", pop.NumOfGen)
+		if generation%1000 == 0 {
+			log.Printf("Number of Generation: %d\n", generation)
+		}
+	}
+	exportGenomes(&cfg, cfg.Prefix, 0, pop)
+	if pw != nil {
+		if err := pw.Close(); err != nil {
+			log.Panic(err)
 		}
 	}
+	if err := ev.Close(); err != nil {
+		log.Panic(err)
+	}
+
 	t1 := time.Now()
 	log.Printf("End at: %v\n", t1)
 	log.Printf("Duration: %v\n", t1.Sub(t0))
@@ -0,0 +1,106 @@
+package hgtfwd
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/sched"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// BenchDiff times the diffs of all pairs of random genomes with the packed
+// kernel of bitseq against the loop over every site, and checks that both
+// find the same sites.
+func BenchDiff(args []string) {
+	fs := flag.NewFlagSet("bench diff", flag.ExitOnError)
+	length := fs.Int("length", 100000, "genome length")
+	sample := fs.Int("sample", 100, "number of genomes")
+	diversity := fs.Float64("diversity", 0.01, "fraction of sites where a genome differs from the ancestor")
+	rounds := fs.Int("rounds", 3, "number of times to diff all pairs")
+	seed := fs.Int64("seed", 1, "random seed")
+	jobs := fs.Int("jobs", 1, "number of goroutines of the packed diffs (0: number of CPUs)")
+	fs.Parse(args)
+	if *length <= 0 || *sample < 2 || *rounds <= 0 {
+		log.Fatal("length and rounds must be positive and sample at least 2")
+	}
+
+	seqs := randomGenomes(*sample, *length, *diversity, rand.New(rand.NewSource(*seed)))
+	pairs := bitseq.AllPairs(len(seqs))
+
+	t0 := time.Now()
+	var loop [][]int
+	for r := 0; r < *rounds; r++ {
+		loop = loop[:0]
+		for _, p := range pairs {
+			diff := []int{}
+			for k := 0; k < *length; k++ {
+				if seqs[p[0]][k] != seqs[p[1]][k] {
+					diff = append(diff, k)
+				}
+			}
+			loop = append(loop, diff)
+		}
+	}
+	tloop := time.Since(t0) / time.Duration(*rounds)
+
+	t0 = time.Now()
+	var g *bitseq.Genomes
+	for r := 0; r < *rounds; r++ {
+		g = bitseq.Pack(seqs)
+	}
+	tpack := time.Since(t0) / time.Duration(*rounds)
+
+	t0 = time.Now()
+	var packed [][]int
+	for r := 0; r < *rounds; r++ {
+		packed = g.Matrix(pairs, *jobs)
+	}
+	tdiff := time.Since(t0) / time.Duration(*rounds)
+
+	for i := range loop {
+		if !sameSites(loop[i], packed[i]) {
+			log.Fatalf("pair %d: the packed diff differs from the loop", i)
+		}
+	}
+
+	fmt.Printf("%d genomes of length %d, %d pairs, %d jobs\n", *sample, *length, len(pairs), sched.Jobs(*jobs))
+	fmt.Printf("loop:   %v\n", tloop)
+	fmt.Printf("packed: %v (pack %v, diff %v)\n", tpack+tdiff, tpack, tdiff)
+	fmt.Printf("speedup: %.1fx\n", float64(tloop)/float64(tpack+tdiff))
+}
+
+// randomGenomes returns n genomes of a random ancestor, each with
+// a fraction diversity of its sites mutated.
+func randomGenomes(n, length int, diversity float64, r *rand.Rand) [][]byte {
+	const alphabet = "ATGC"
+	ancestor := make([]byte, length)
+	for k := range ancestor {
+		ancestor[k] = alphabet[r.Intn(len(alphabet))]
+	}
+	seqs := make([][]byte, n)
+	for i := range seqs {
+		s := append([]byte(nil), ancestor...)
+		for k := range s {
+			if r.Float64() < diversity {
+				s[k] = alphabet[r.Intn(len(alphabet))]
+			}
+		}
+		seqs[i] = s
+	}
+	return seqs
+}
+
+// sameSites returns whether the diffs x and y list the same sites.
+func sameSites(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
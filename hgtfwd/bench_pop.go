@@ -1,23 +1,39 @@
-package main
+package hgtfwd
 
 import (
+	"flag"
 	"fmt"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/rng"
 	fwd "github.com/mingzhi/hgt/fwd"
+	"log"
 	"time"
 )
 
-func main() {
-	t0 := time.Now()
+// Bench times the evolution of a population.
+func Bench(args []string) {
+	cfg := config.Config{
+		Size:     1000,
+		Length:   10000,
+		Mutation: 0.001,
+		Transfer: 0.001,
+		Fragment: 1000,
+		Gens:     1000,
+	}
 
-	size := 1000
-	length := 10000
-	mutation := 0.001
-	transfer := 0.001
-	fragment := 1000
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Gens, "gens", cfg.Gens, "number of generations")
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+
+	t0 := time.Now()
 
-	pop := fwd.NewSeqPop(size, length, mutation, transfer, fragment)
+	pop := fwd.NewSeqPop(cfg.Size, cfg.Length, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	pop.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, 0)))
 
-	n := 1000
+	n := cfg.Gens
 	for i := 0; i < n; i++ {
 		pop.Evolve()
 	}
@@ -0,0 +1,26 @@
+package hgtfwd
+
+import (
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/seqio"
+	"github.com/mingzhi/hgt/fwd"
+	"log"
+)
+
+// exportGenomes exports a sample of the genomes of pop, the population
+// of replicate i, to name if an export format is set in cfg.
+func exportGenomes(cfg *config.Config, name string, i int, pop *fwd.SeqPop) {
+	if err := seqio.Save(cfg, name, i, genomes(pop)); err != nil {
+		log.Panic(err)
+	}
+}
+
+// genomes returns the genomes of pop as byte slices.
+func genomes(pop *fwd.SeqPop) [][]byte {
+	g := pop.GetGenomes()
+	seqs := make([][]byte, len(g))
+	for j := range g {
+		seqs[j] = g[j]
+	}
+	return seqs
+}
@@ -1,200 +1,256 @@
-package main
+package hgtfwd
 
 import (
 	"flag"
 	"fmt"
-	"github.com/mingzhi/gomath/stat/desc"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/checkpoint"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/cov"
+	"github.com/mingzhi/gomain/events"
+	"github.com/mingzhi/gomain/pairs"
+	"github.com/mingzhi/gomain/registry"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/rng"
+	"github.com/mingzhi/gomain/sched"
+	"github.com/mingzhi/gomain/stats"
 	"github.com/mingzhi/hgt/covs"
 	"github.com/mingzhi/hgt/fwd"
 	"log"
-	"math/rand"
 	"os"
 	"runtime"
 )
 
-var (
-	size     int     // popoulation size
-	reps     int     // replications
-	lens     int     // genome lengths
-	maxl     int     // max l
-	frag     int     // fragment length
-	gens     int     // number of generations
-	samp     int     // number of pairs to calculate
-	mutation float64 // mutation rate
-	transfer float64 // transfer rate
-	prefix   string  // prefix
-	exptime  bool    // ExpTime scale for selection
-)
-
-type Moment struct {
-	Mean *desc.Mean
-	Sd   *desc.StandardDeviation
-}
-
-func (m *Moment) Increment(d float64) {
-	m.Mean.Increment(d)
-	m.Sd.Increment(d)
-}
-
 type Result struct {
+	index                              int // replicate index
 	ks, vd                             float64
 	scovs, rcovs, xyPL, xsysPL, smXYPL []float64
 }
 
-func init() {
-	flag.IntVar(&size, "size", 1000, "population size")
-	flag.IntVar(&lens, "genome", 1000, "genome length")
-	flag.IntVar(&frag, "frag", 100, "fragment length (ratio)")
-	flag.IntVar(&reps, "reps", 1000, "repeats")
-	flag.IntVar(&maxl, "maxl", 100, "maxl")
-	flag.IntVar(&gens, "gens", 10000, "number of generations")
-	flag.IntVar(&samp, "sample", 1000, "number of pairs to calculate")
-	flag.Float64Var(&transfer, "transfer", 1e-4, "transfer rate")
-	flag.Float64Var(&mutation, "mutation", 1e-4, "mutation rate")
-	flag.StringVar(&prefix, "prefix", "test", "prefix")
-	flag.BoolVar(&exptime, "exptime", false, "Exp time for Wright-Fisher selection")
-
-	flag.Parse()
-	if maxl < 2*frag {
-		maxl = 2 * frag
-	}
+// hpcState is the checkpoint of the replicates collected so far.
+// Replicates are collected in index order, so they are all below Next.
+type hpcState struct {
+	Config  config.Config // parameters of the run
+	Run     int64         // id of the run in the -db database, 0 if none
+	Range   [2]int        // replicates of the shard
+	Next    int           // index of the next replicate to collect
+	Moments *stats.Covs   // covariance moments of the replicates collected
+	DSize   int64         // bytes written to the d file
 }
 
-func main() {
-	ncpu := runtime.NumCPU()
-	runtime.GOMAXPROCS(ncpu)
+// HPC simulates independent replicates of a population on all CPUs
+// and accumulates the covariance moments over the replicates.
+func HPC(args []string) {
+	cfg := config.Config{
+		Size:     1000,
+		Length:   1000,
+		Fragment: 100,
+		Reps:     1000,
+		MaxL:     100,
+		Gens:     10000,
+		Sample:   1000,
+		Pairs:    "replace",
+		Mutation: 1e-4,
+		Transfer: 1e-4,
+		Prefix:   "test",
+	}
+	cfg.Checkpoint = -1 // every percent of the replicates of the shard, at least 1
+
+	fs := flag.NewFlagSet("fwd hpc", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Reps, "reps", cfg.Reps, "repeats")
+	fs.IntVar(&cfg.Gens, "gens", cfg.Gens, "number of generations")
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "number of pairs to calculate")
+	cfg.RegisterPairsFlag(fs)
+	fs.BoolVar(&cfg.ExpTime, "exptime", cfg.ExpTime, "Exp time for Wright-Fisher selection")
+	fs.IntVar(&cfg.Checkpoint, "checkpoint", cfg.Checkpoint, "replicates between checkpoints (0: no checkpoints, -1: one percent of the replicates of the shard)")
+	fs.BoolVar(&cfg.Resume, "resume", cfg.Resume, "resume from the last checkpoint")
+	cfg.RegisterJobsFlag(fs)
+	cfg.RegisterShardFlag(fs)
+	cfg.RegisterLinearFlag(fs)
+	cfg.RegisterParquetFlag(fs)
+	cfg.RegisterDBFlag(fs)
+	cfg.RegisterEventsFlag(fs)
+	cfg.RegisterExportFlags(fs)
+
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	cfg.AdjustMaxL()
+	newSampler(&cfg) // check the pair sampling before the replicates start
 
-	ch := make(chan Result, ncpu)
+	// replicates of this shard
+	b, e := cfg.ShardRange(cfg.Reps)
+	reps := e - b
+	step := results.ProgressStep(reps)
+	if cfg.Checkpoint < 0 {
+		cfg.Checkpoint = results.ProgressStep(reps)
+	}
+	dname := cfg.Out("_d.csv")
+	ckname := cfg.Out(".ckpt")
+
+	// the checkpoint to resume from
+	state := hpcState{
+		Config:  cfg,
+		Range:   [2]int{b, e},
+		Next:    b,
+		Moments: stats.NewCovs(cfg.MaxL),
+	}
+	if cfg.Resume {
+		var saved hpcState
+		if err := checkpoint.Load(ckname, &saved); err != nil {
+			log.Fatal(err)
+		}
+		if err := cfg.CheckResume(&saved.Config); err != nil {
+			log.Fatal(err)
+		}
+		if saved.Range != state.Range {
+			log.Fatalf("cannot resume: the checkpoint has replicates %d-%d but this shard %d-%d",
+				saved.Range[0], saved.Range[1], b, e)
+		}
+		state.Run, state.Next, state.Moments, state.DSize = saved.Run, saved.Next, saved.Moments, saved.DSize
+	}
 
-	for i := 0; i < ncpu; i++ {
-		b := i * reps / ncpu
-		e := (i + 1) * reps / ncpu
-		go simulateSome(b, e, ch)
+	run, err := registry.Resume("fwd hpc", &cfg, state.Run)
+	if err != nil {
+		log.Fatal(err)
+	}
+	state.Run = run.ID()
+	ev, err := events.Start("fwd hpc", &cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	dfile, err := os.Create(fmt.Sprintf("%s_d.csv", prefix))
+	// create d file, or continue the one of the checkpoint
+	var dfile *os.File
+	if cfg.Resume {
+		dfile, err = checkpoint.Reopen(dname, state.DSize)
+		log.Printf("Resume after %d replicates\n", state.Next-b)
+	} else {
+		dfile, err = results.CreateD(dname, cfg.Header())
+	}
 	if err != nil {
 		log.Panic(err)
 	}
 	defer dfile.Close()
 
-	dfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-	dfile.WriteString(fmt.Sprintf("#length: %d\n", lens))
-	dfile.WriteString(fmt.Sprintf("#fragment: %d\n", frag))
-	dfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-	dfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-	dfile.WriteString(fmt.Sprintf("#generations: %d\n", gens))
-	dfile.WriteString(fmt.Sprintf("#sample: %d\n", samp))
-	dfile.WriteString("#ks, vd\n")
-
-	moments := make([][]Moment, 5)
-	for i := 0; i < len(moments); i++ {
-		for j := 0; j < maxl; j++ {
-			m := Moment{
-				Mean: desc.NewMean(),
-				Sd:   desc.NewStandardDeviationWithBiasCorrection(),
-			}
-			moments[i] = append(moments[i], m)
-		}
-	}
+	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	for i := 0; i < reps; i++ {
-		result := <-ch
+	// simulate the replicates that are not done yet,
+	// and collect them in replicate order.
+	moments := state.Moments
+	err = sched.RunOrdered(state.Next, e, cfg.Jobs, func(i int) interface{} {
+		return simulate(&cfg, i)
+	}, func(i int, v interface{}) error {
+		result := v.(Result)
 
-		dfile.WriteString(fmt.Sprintf("%g, %g\n", result.ks, result.vd))
-		for j := 0; j < maxl; j++ {
-			moments[0][j].Increment(result.scovs[j])
-			moments[1][j].Increment(result.rcovs[j])
-			moments[2][j].Increment(result.xyPL[j])
-			moments[3][j].Increment(result.xsysPL[j])
-			moments[4][j].Increment(result.smXYPL[j])
+		if err := results.WriteD(dfile, result.ks, result.vd); err != nil {
+			return err
 		}
+		if err := ev.Replicate(i, result.ks, result.vd); err != nil {
+			return err
+		}
+		moments.Increment(result.scovs, result.rcovs, result.xyPL, result.xsysPL, result.smXYPL)
+		state.Next = i + 1
+		n := i + 1 - b // replicates collected
 
-		if (i+1)%(reps/100) == 0 {
-			err = dfile.Sync()
+		if cfg.Checkpoint > 0 && n%cfg.Checkpoint == 0 {
+			size, err := checkpoint.Offset(dfile)
 			if err != nil {
-				log.Panic(err)
+				return err
 			}
-			cfile, err := os.Create(fmt.Sprintf("%s_covs.csv", prefix))
-			if err != nil {
-				log.Panic(err)
+			state.DSize = size
+			if err := checkpoint.Save(ckname, &state); err != nil {
+				return err
+			}
+		}
+
+		if n%step == 0 {
+			if err := dfile.Sync(); err != nil {
+				return err
 			}
 
-			cfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-			cfile.WriteString(fmt.Sprintf("#length: %d\n", lens))
-			cfile.WriteString(fmt.Sprintf("#fragment: %d\n", frag))
-			cfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-			cfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-			cfile.WriteString(fmt.Sprintf("#generations: %d\n", gens))
-			cfile.WriteString(fmt.Sprintf("#replicates: %d\n", i+1))
-			cfile.WriteString(fmt.Sprintf("#sample: %d\n", samp))
-			cfile.WriteString("#dist, scov, rcov, xy, xsys, smxy_sd, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n")
-
-			for j := 0; j < maxl; j++ {
-				cfile.WriteString(fmt.Sprintf("%d,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g\n",
-					j,
-					moments[0][j].Mean.GetResult(),
-					moments[1][j].Mean.GetResult(),
-					moments[2][j].Mean.GetResult(),
-					moments[3][j].Mean.GetResult(),
-					moments[4][j].Mean.GetResult(),
-					moments[0][j].Sd.GetResult(),
-					moments[1][j].Sd.GetResult(),
-					moments[2][j].Sd.GetResult(),
-					moments[3][j].Sd.GetResult(),
-					moments[4][j].Sd.GetResult(),
-				))
+			h := cfg.Header()
+			h.Add("replicates", n)
+			err := results.WriteCovs(cfg.Out("_covs.csv"), h, moments)
+			if err != nil {
+				return err
 			}
-			cfile.Close()
-			log.Printf("Finish %%%d\n", (i+1)/(reps/100))
+			log.Printf("Finish %%%d\n", n*100/reps)
 		}
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
 	}
-}
 
-func simulateSome(b, e int, ch chan Result) {
-	for i := b; i < e; i++ {
-		sp := fwd.NewSeqPop(size, lens, mutation, transfer, frag)
-		sp.SetExpTime(exptime)
-		sp.Seed(i)
-		for j := 0; j < gens; j++ {
-			sp.Evolve()
+	if cfg.Sharded() {
+		h := cfg.Header()
+		h.Add("replicates", reps)
+		if err := results.WriteMoments(cfg.Out("_moments.csv"), h, moments); err != nil {
+			log.Panic(err)
 		}
+	}
 
-		seqs := sp.GetGenomes()
-
-		diffmatrix := [][]int{}
-		for j := 0; j < samp; j++ {
-			a := rand.Intn(size)
-			b := rand.Intn(size)
-			for a == b {
-				b = rand.Intn(size)
-			}
+	index := func(i int) (int, int) {
+		return b + i, cfg.Gens
+	}
+	if cfg.Parquet {
+		if err := columnar.Save(cfg.Out(".parquet"), &cfg, dname, index, moments); err != nil {
+			log.Panic(err)
+		}
+	}
+	if err := run.Save(&cfg, dname, index, moments); err != nil {
+		log.Panic(err)
+	}
+	if err := ev.Close(); err != nil {
+		log.Panic(err)
+	}
+}
 
-			diff := []int{}
+// newSampler returns the pair sampler of cfg.
+func newSampler(cfg *config.Config) pairs.Sampler {
+	s, err := pairs.New(cfg.Pairs, cfg.Sample)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
 
-			for k := 0; k < lens; k++ {
-				if seqs[a][k] != seqs[b][k] {
-					diff = append(diff, k)
-				}
-			}
+// simulate simulates replicate i.
+func simulate(cfg *config.Config, i int) Result {
+	size, lens := cfg.Size, cfg.Length
+	sp := fwd.NewSeqPop(size, lens, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	sp.SetExpTime(cfg.ExpTime)
+	sp.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, i)))
+	for j := 0; j < cfg.Gens; j++ {
+		sp.Evolve()
+	}
+	exportGenomes(cfg, fmt.Sprintf("%s_rep%d", cfg.Prefix, i), i, sp)
 
-			diffmatrix = append(diffmatrix, diff)
-		}
+	r := rng.New(cfg.Seed, rng.Sampling, i)
+	drawn, err := newSampler(cfg).Pairs(size, r)
+	if err != nil {
+		log.Panic(err)
+	}
+	// the replicates already run in parallel
+	diffmatrix := bitseq.DiffMatrix(genomes(sp), drawn, 1)
 
-		cmatrix := covs.NewCMatrix(samp, lens, diffmatrix)
-
-		ks, vd := cmatrix.D()
-		scovs, rcovs, xyPL, xsysPL, smXYPL := cmatrix.CovCircle(maxl)
-		result := Result{
-			ks:     ks,
-			vd:     vd,
-			scovs:  scovs,
-			rcovs:  rcovs,
-			xyPL:   xyPL,
-			xsysPL: xsysPL,
-			smXYPL: smXYPL,
-		}
+	cmatrix := covs.NewCMatrix(len(diffmatrix), lens, diffmatrix)
 
-		ch <- result
+	ks, vd := cmatrix.D()
+	scovs, rcovs, xyPL, xsysPL, smXYPL := cov.Series(cfg.Linear, cmatrix, diffmatrix, lens, cfg.MaxL)
+	result := Result{
+		index:  i,
+		ks:     ks,
+		vd:     vd,
+		scovs:  scovs,
+		rcovs:  rcovs,
+		xyPL:   xyPL,
+		xsysPL: xsysPL,
+		smXYPL: smXYPL,
 	}
+
+	return result
 }
@@ -1,157 +1,193 @@
-package main
+package hgtfwd
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/mingzhi/gomath/stat/desc"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/checkpoint"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/cov"
+	"github.com/mingzhi/gomain/events"
+	"github.com/mingzhi/gomain/registry"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/rng"
+	"github.com/mingzhi/gomain/stats"
 	"github.com/mingzhi/hgt/covs"
 	"github.com/mingzhi/hgt/fwd"
 	"log"
-	"math/rand"
 	"os"
 )
 
-type Moment struct {
-	Mean *desc.Mean
-	Sd   *desc.StandardDeviation
+// singleState is the checkpoint of a single population run.
+type singleState struct {
+	Config     config.Config   // parameters of the run
+	Run        int64           // id of the run in the -db database, 0 if none
+	Generation int             // generations done, including equilibration
+	Population json.RawMessage // population saved by SeqPop.Json
+	Moments    *stats.Covs     // covariance moments of the sampled generations
+	DSize      int64           // bytes written to the d file
 }
 
-func (m *Moment) Increment(d float64) {
-	m.Mean.Increment(d)
-	m.Sd.Increment(d)
-}
-
-var (
-	size     int     // population size
-	lens     int     // genome length
-	mutation float64 // mutation rate per site per generation
-	transfer float64 // transfer rate per site per generation
-	frag     int     // fragment length to transfer
-	maxl     int     // max distance to calculate
-	gens     int     // total generations to sample after 10000 generation
-	prefix   string  // prefix
-	eqvGens  int     // generations to reach equiliquim
-	samp     int     // number of pairs to calculate
-)
+// Single evolves one population to equilibrium and then samples it
+// every generation, accumulating the covariance moments over time.
+func Single(args []string) {
+	cfg := config.Config{
+		Size:       1000,
+		Length:     1000,
+		Fragment:   100,
+		MaxL:       200,
+		Gens:       1000,
+		EqvGens:    10000,
+		Sample:     1000,
+		Pairs:      "replace",
+		Mutation:   1e-4,
+		Transfer:   1e-4,
+		Checkpoint: 1000,
+		Prefix:     "test",
+	}
 
-func init() {
 	// register flags
-	flag.IntVar(&size, "size", 1000, "population size")
-	flag.IntVar(&lens, "genome", 1000, "genome length")
-	flag.IntVar(&frag, "frag", 100, "fragment length to transfer")
-	flag.IntVar(&maxl, "maxl", 200, "max distance to calculate")
-	flag.IntVar(&gens, "gens", 1000, "total generations to sample after 10000 generation")
-	flag.Float64Var(&transfer, "transfer", 1e-4, "transfer rate per site per generation")
-	flag.Float64Var(&mutation, "mutation", 1e-4, "mutation rate per site per generation")
-	flag.StringVar(&prefix, "prefix", "test", "prefix")
-	flag.IntVar(&eqvGens, "eqv", 10000, "generations to reach equiliquim")
-	flag.IntVar(&samp, "sample", 1000, "number of pairs to calculate")
+	fs := flag.NewFlagSet("fwd single", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Gens, "gens", cfg.Gens, "total generations to sample after reaching equilibrium")
+	fs.IntVar(&cfg.EqvGens, "eqv", cfg.EqvGens, "generations to reach equilibrium")
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "number of pairs to calculate")
+	cfg.RegisterPairsFlag(fs)
+	fs.IntVar(&cfg.Checkpoint, "checkpoint", cfg.Checkpoint, "generations between checkpoints (0: no checkpoints)")
+	fs.BoolVar(&cfg.Resume, "resume", cfg.Resume, "resume from the last checkpoint")
+	cfg.RegisterLinearFlag(fs)
+	cfg.RegisterParquetFlag(fs)
+	cfg.RegisterDBFlag(fs)
+	cfg.RegisterEventsFlag(fs)
+	cfg.RegisterExportFlags(fs)
 
 	// parse flags
-	flag.Parse()
-}
-
-func main() {
-	// create d file
-	dfile, err := os.Create(fmt.Sprintf("%s_d.csv", prefix))
-	if err != nil {
-		log.Panic(err)
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
 	}
-	defer dfile.Close()
 
-	// create population for simulation
-	sp := fwd.NewSeqPop(size, lens, mutation, transfer, frag)
+	size, lens, maxl, gens, prefix := cfg.Size, cfg.Length, cfg.MaxL, cfg.Gens, cfg.Prefix
+	sampler := newSampler(&cfg)
+	dname := fmt.Sprintf("%s_d.csv", prefix)
+	ckname := fmt.Sprintf("%s.ckpt", prefix)
 
-	// do eqvGens generations for reaching equilibrium
-	for i := 0; i < eqvGens; i++ {
-		sp.Evolve()
-	}
+	// create population for simulation
+	sp := fwd.NewSeqPop(size, lens, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	// random source for choosing the sampled pairs
+	r := rng.New(cfg.Seed, rng.Sampling, 0)
 
 	// create moments
-	moments := make([][]Moment, 5)
-	for i := 0; i < len(moments); i++ {
-		for j := 0; j < maxl; j++ {
-			m := Moment{
-				Mean: desc.NewMean(),
-				Sd:   desc.NewStandardDeviationWithBiasCorrection(),
-			}
-			moments[i] = append(moments[i], m)
+	moments := stats.NewCovs(maxl)
+
+	// the checkpoint to resume from
+	var state singleState
+	if cfg.Resume {
+		if err := checkpoint.Load(ckname, &state); err != nil {
+			log.Fatal(err)
+		}
+		if err := cfg.CheckResume(&state.Config); err != nil {
+			log.Fatal(err)
 		}
+		if err := json.Unmarshal(state.Population, sp); err != nil {
+			log.Fatal(err)
+		}
+		moments = state.Moments
 	}
 
-	// do sample generations
-	for i := 0; i < gens; i++ {
-		sp.Evolve()
-
-		seqs := sp.GetGenomes()
+	run, err := registry.Resume("fwd single", &cfg, state.Run)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ev, err := events.Start("fwd single", &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params := columnar.ParamsOf(&cfg)
+
+	// create d file, or continue the one of the checkpoint
+	var dfile *os.File
+	start := state.Generation
+	if cfg.Resume {
+		dfile, err = checkpoint.Reopen(dname, state.DSize)
+		log.Printf("Resume at generation %d\n", start)
+	} else {
+		dfile, err = results.CreateD(dname, cfg.Header())
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+	defer dfile.Close()
 
-		diffmatrix := [][]int{}
-		for j := 0; j < samp; j++ {
-			a := rand.Intn(size)
-			b := rand.Intn(size)
-			for a == b {
-				b = rand.Intn(size)
+	// do eqvGens generations for reaching equilibrium,
+	// and then the sample generations
+	total := cfg.EqvGens + gens
+	step := results.ProgressStep(gens)
+	for g := start; g < total; g++ {
+		// the random streams are reseeded at every checkpoint,
+		// so that a resumed run continues exactly like an uninterrupted one.
+		if g == 0 || cfg.Checkpoint > 0 && g%cfg.Checkpoint == 0 {
+			if g > start {
+				saveSingle(ckname, &cfg, run.ID(), g, sp, moments, dfile)
 			}
+			sp.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, g)))
+			r.Seed(rng.Seed(cfg.Seed, rng.Sampling, g))
+		}
 
-			diff := []int{}
+		sp.Evolve()
 
-			for k := 0; k < lens; k++ {
-				if seqs[a][k] != seqs[b][k] {
-					diff = append(diff, k)
-				}
-			}
+		i := g - cfg.EqvGens
+		if i < 0 {
+			continue
+		}
 
-			diffmatrix = append(diffmatrix, diff)
+		drawn, err := sampler.Pairs(size, r)
+		if err != nil {
+			log.Panic(err)
 		}
+		diffmatrix := bitseq.DiffMatrix(genomes(sp), drawn, 0)
 
-		cmatrix := covs.NewCMatrix(samp, lens, diffmatrix)
+		cmatrix := covs.NewCMatrix(len(diffmatrix), lens, diffmatrix)
 
 		ks, vd := cmatrix.D()
-		dfile.WriteString(fmt.Sprintf("%g,%g\n", ks, vd))
-
-		scovs, rcovs, xyPL, xsysPL, smXYPL := cmatrix.CovCircle(maxl)
-
-		for j := 0; j < maxl; j++ {
-			moments[0][j].Increment(scovs[j])
-			moments[1][j].Increment(rcovs[j])
-			moments[2][j].Increment(xyPL[j])
-			moments[3][j].Increment(xsysPL[j])
-			moments[4][j].Increment(smXYPL[j])
+		if err := results.WriteD(dfile, ks, vd); err != nil {
+			log.Panic(err)
 		}
+		if err := ev.Generation(params, 0, g+1, ks, vd); err != nil {
+			log.Panic(err)
+		}
+
+		scovs, rcovs, xyPL, xsysPL, smXYPL := cov.Series(cfg.Linear, cmatrix, diffmatrix, lens, maxl)
+		moments.Increment(scovs, rcovs, xyPL, xsysPL, smXYPL)
 
-		if (i+1)%(gens/100) == 0 {
-			cfile, err := os.Create(fmt.Sprintf("%s_covs.csv", prefix))
+		if (i+1)%step == 0 {
+			h := cfg.Header()
+			h.Add("replicates", i+1)
+			err := results.WriteCovs(fmt.Sprintf("%s_covs.csv", prefix), h, moments)
 			if err != nil {
 				log.Panic(err)
 			}
+			log.Printf("Finish %%%d\n", (i+1)*100/gens)
+		}
+	}
 
-			cfile.WriteString(fmt.Sprintf("#size: %d\n", size))
-			cfile.WriteString(fmt.Sprintf("#length: %d\n", lens))
-			cfile.WriteString(fmt.Sprintf("#fragment: %d\n", frag))
-			cfile.WriteString(fmt.Sprintf("#mutation: %g\n", mutation))
-			cfile.WriteString(fmt.Sprintf("#transfer: %g\n", transfer))
-			cfile.WriteString(fmt.Sprintf("#generations: %d\n", i+1))
-			cfile.WriteString("dist, scov, rcov, xy, xsys, smxy_sd, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n")
-
-			for j := 0; j < maxl; j++ {
-				cfile.WriteString(fmt.Sprintf("%d,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g\n",
-					j,
-					moments[0][j].Mean.GetResult(),
-					moments[1][j].Mean.GetResult(),
-					moments[2][j].Mean.GetResult(),
-					moments[3][j].Mean.GetResult(),
-					moments[4][j].Mean.GetResult(),
-					moments[0][j].Sd.GetResult(),
-					moments[1][j].Sd.GetResult(),
-					moments[2][j].Sd.GetResult(),
-					moments[3][j].Sd.GetResult(),
-					moments[4][j].Sd.GetResult(),
-				))
-			}
-			cfile.Close()
-			log.Printf("Finish %%%d\n", (i+1)/(gens/100))
+	exportGenomes(&cfg, prefix, 0, sp)
+
+	index := func(i int) (int, int) {
+		return 0, cfg.EqvGens + i + 1
+	}
+	if cfg.Parquet {
+		if err := columnar.Save(prefix+".parquet", &cfg, dname, index, moments); err != nil {
+			log.Panic(err)
 		}
 	}
+	if err := run.Save(&cfg, dname, index, moments); err != nil {
+		log.Panic(err)
+	}
+	if err := ev.Close(); err != nil {
+		log.Panic(err)
+	}
 
 	// save the population for further use
 	pfile, err := os.Create(fmt.Sprintf("%s.json", prefix))
@@ -162,3 +198,23 @@ func main() {
 
 	pfile.Write(sp.Json())
 }
+
+// saveSingle saves the state after g generations of a run with cfg,
+// recorded as run id in the database, to the checkpoint file.
+func saveSingle(filename string, cfg *config.Config, id int64, g int, sp *fwd.SeqPop, moments *stats.Covs, dfile *os.File) {
+	dsize, err := checkpoint.Offset(dfile)
+	if err != nil {
+		log.Panic(err)
+	}
+	state := singleState{
+		Config:     *cfg,
+		Run:        id,
+		Generation: g,
+		Population: sp.Json(),
+		Moments:    moments,
+		DSize:      dsize,
+	}
+	if err := checkpoint.Save(filename, &state); err != nil {
+		log.Panic(err)
+	}
+}
@@ -1,83 +1,105 @@
-package main
+package hgtfwd
 
 import (
 	"flag"
+	"fmt"
 	"github.com/mingzhi/chart/render"
-	"github.com/mingzhi/gomath/random"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/rng"
+	"github.com/mingzhi/gomain/sched"
 	"github.com/mingzhi/gomath/stat/desc"
 	"github.com/mingzhi/hgt/covs"
-	"github.com/mingzhi/hgt/fwd1"
+	"github.com/mingzhi/hgt/fwd"
 	"github.com/vdobler/chart"
-	"math/rand"
-	"runtime"
+	"log"
+	"os"
 )
 
-var (
-	size       int          // population size
-	length     int          // genome length
-	fragment   int          // transferred fragment
-	mutation   float64      // mutation rate
-	transfer   float64      // transfer rate
-	ngen       int          // number of generation we want to evolve
-	sampleSize int          // sample size
-	pop        *fwd1.SeqPop // population
-	fname      string       // file name
-)
-
-func init() {
-	//assign flags
-	flag.IntVar(&size, "size", 1000, "usage: -size 1000")
-	flag.IntVar(&length, "length", 1000, "usage: -length 1000")
-	flag.IntVar(&ngen, "ngen", 1000, "usage: -ngen 1000")
-	flag.IntVar(&sampleSize, "samplesize", 100, "usage: -samplesize 1000")
-	flag.IntVar(&fragment, "fragment", 100, "usage: -fragment 1000")
-	flag.Float64Var(&mutation, "mutation", 1e-5, "usage: -mutation 1e-5")
-	flag.Float64Var(&transfer, "transfer", 0.0, "usage: -transfer 1e-9")
-	flag.StringVar(&fname, "fname", "ks", "usage: -fname ks")
+// Cvg follows the convergence of ks and vd to equilibrium: at every
+// generation it averages them over several random samples, computed
+// at once, and plots them against time.
+func Cvg(args []string) {
+	cfg := config.Config{
+		Size:     1000,
+		Length:   1000,
+		Gens:     1000,
+		Sample:   100,
+		Pairs:    "subset",
+		Fragment: 100,
+		Mutation: 1e-5,
+		Transfer: 0.0,
+		Prefix:   "cvg",
+	}
 
-	// parse flags
-	flag.Parse()
+	fs := flag.NewFlagSet("fwd cvg", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Gens, "gens", cfg.Gens, "number of generations")
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "sample size")
+	samples := fs.Int("samples", 10, "number of samples to average at every generation")
+	cfg.RegisterPairsFlag(fs)
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
 
-	// create random source (locked)
-	src := random.NewLockedSource(rand.NewSource(1))
+	pop := fwd.NewSeqPop(cfg.Size, cfg.Length, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	pop.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, 0)))
+	r := rng.New(cfg.Seed, rng.Sampling, 0)
+	sampler := newSampler(&cfg)
 
-	// construct a population
-	pop = fwd1.NewSeqPop(size, length, mutation, transfer, fragment, src)
-	// use all the available CPUs
-	runtime.GOMAXPROCS(runtime.NumCPU())
-}
+	f, err := os.Create(cfg.Prefix + ".csv")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := cfg.Header().Write(f); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := f.WriteString("#generation, ks, vd\n"); err != nil {
+		log.Fatal(err)
+	}
 
-// the converge of KS equalibrium.
-func main() {
 	ksarray := []float64{} // store ks
 	vdarray := []float64{} // store VarD
 	ngarray := []float64{} // store generation number
-	// do evolution
-	for i := 0; i < ngen; i++ {
+
+	ks := make([]float64, *samples)
+	vd := make([]float64, *samples)
+	for i := 0; i < cfg.Gens; i++ {
 		pop.Evolve()
-		// select 10 samples and averge
-		mean := desc.NewMean()
-		vmean := desc.NewMean()
-		ch := make(chan dResult)
-		num := 10
-		for j := 0; j < num; j++ {
-			sample := fwd1.Sample(pop.Genomes, sampleSize)
-			go calculateD(sample, pop.Length, ch)
+		generation := i + 1
+		seqs := genomes(pop)
+
+		// draw the samples in order, then diff them at once
+		drawn := make([][]bitseq.Pair, *samples)
+		for j := range drawn {
+			drawn[j], err = sampler.Pairs(cfg.Size, r)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
+		sched.Run(*samples, 0, func(j int) {
+			dmatrix := bitseq.DiffMatrix(seqs, drawn[j], 1)
+			ks[j], vd[j] = covs.NewCMatrix(len(dmatrix), cfg.Length, dmatrix).D()
+		})
 
-		for j := 0; j < num; j++ {
-			dr := <-ch
-			mean.Increment(dr.ks)
-			vmean.Increment(dr.vard)
+		ksmean := desc.NewMean()
+		vdmean := desc.NewMean()
+		for j := range ks {
+			ksmean.Increment(ks[j])
+			vdmean.Increment(vd[j])
+		}
+		if _, err := fmt.Fprintf(f, "%d,%g,%g\n", generation, ksmean.GetResult(), vdmean.GetResult()); err != nil {
+			log.Panic(err)
 		}
 
-		ksarray = append(ksarray, mean.GetResult())
-		vdarray = append(vdarray, vmean.GetResult())
-		ngarray = append(ngarray, float64(pop.NumOfGen))
+		ksarray = append(ksarray, ksmean.GetResult())
+		vdarray = append(vdarray, vdmean.GetResult())
+		ngarray = append(ngarray, float64(generation))
 	}
 
 	// draw
-	svger := render.NewSVG(fname, 1, 2, 800, 200)
+	svger := render.NewSVG(cfg.Prefix, 1, 2, 800, 200)
 	pl := chart.ScatterChart{Title: "KS"}
 	pl.AddDataPair("KS", ngarray, ksarray, chart.PlotStyleLines, chart.Style{Symbol: '+', SymbolColor: "#0000ff", LineStyle: chart.SolidLine})
 	svger.Plot(&pl)
@@ -86,15 +108,3 @@ func main() {
 	svger.Plot(&pl)
 	svger.Close()
 }
-
-type dResult struct {
-	ks, vard float64
-}
-
-func calculateD(sample []fwd1.Sequence, length int, ch chan dResult) {
-	dmatrix := fwd1.GenerateDistanceMatrix(sample)
-	cmatrix := covs.NewCMatrix(sampleSize, length, dmatrix)
-	ks, vard := cmatrix.D()
-	dr := dResult{ks: ks, vard: vard}
-	ch <- dr
-}
@@ -1,88 +1,134 @@
-package main
+package hgtfwd
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/mingzhi/chart/render"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/events"
+	"github.com/mingzhi/gomain/rng"
 	"github.com/mingzhi/gomath/stat/desc"
 	"github.com/mingzhi/hgt/covs"
-	fwd "github.com/mingzhi/hgt/fwd1"
+	"github.com/mingzhi/hgt/fwd"
 	"github.com/vdobler/chart"
+	"log"
 	"os"
 	"runtime"
 )
 
-var (
-	size       int         // population size
-	length     int         // genome length
-	fragment   int         // transferred fragment
-	mutation   float64     // mutation rate
-	transfer   float64     // transfer rate
-	ngen       int         // number of generation we want to evolve
-	sampleSize int         // sample size
-	sampleTime int         // sample times
-	pop        *fwd.SeqPop // population
-	fname      string      // file name
-)
+// KS evolves a population and records the mean ks and vd
+// of several samples at every generation.
+func KS(args []string) {
+	cfg := config.Config{
+		Size:     1000,
+		Length:   1000,
+		Gens:     1000,
+		Sample:   100,
+		Pairs:    "subset",
+		Fragment: 100,
+		Mutation: 1e-5,
+		Transfer: 0.0,
+		Prefix:   "ks",
+	}
 
-func init() {
 	//assign flags
-	flag.IntVar(&size, "size", 1000, "usage: -size 1000")
-	flag.IntVar(&length, "length", 1000, "usage: -length 1000")
-	flag.IntVar(&ngen, "ngen", 1000, "usage: -ngen 1000")
-	flag.IntVar(&sampleSize, "samplesize", 100, "usage: -samplesize 1000")
-	flag.IntVar(&sampleTime, "sampletime", 1, "usage: -sampletime 10")
-	flag.IntVar(&fragment, "fragment", 100, "usage: -fragment 1000")
-	flag.Float64Var(&mutation, "mutation", 1e-5, "usage: -mutation 1e-5")
-	flag.Float64Var(&transfer, "transfer", 0.0, "usage: -transfer 1e-9")
-	flag.StringVar(&fname, "fname", "ks", "usage: -fname ks")
+	fs := flag.NewFlagSet("fwd ks", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Gens, "gens", cfg.Gens, "number of generation we want to evolve")
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "sample size")
+	sampleTime := fs.Int("sampletime", 1, "sample times")
+	cfg.RegisterPairsFlag(fs)
+	cfg.RegisterExportFlags(fs)
+	cfg.RegisterEventsFlag(fs)
+	cfg.RegisterParquetFlag(fs)
 
 	// parse flags
-	flag.Parse()
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+
+	ev, err := events.Start("fwd ks", &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params := columnar.ParamsOf(&cfg)
 
 	// construct a population
-	pop = fwd.NewSeqPop(size, length, mutation, transfer, fragment)
+	pop := fwd.NewSeqPop(cfg.Size, cfg.Length, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	pop.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, 0)))
+	r := rng.New(cfg.Seed, rng.Sampling, 0)
 	// use all the available CPUs
 	runtime.GOMAXPROCS(runtime.NumCPU())
-}
 
-func main() {
+	fname := cfg.Prefix
+	sampler := newSampler(&cfg)
+
 	// create file storing ks and vard
 	f, err := os.Create(fname + ".csv")
 	if err != nil {
 		panic(err)
 	}
 	defer f.Close()
+	if err := cfg.Header().Write(f); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := f.WriteString("#generation, ks, vd\n"); err != nil {
+		log.Fatal(err)
+	}
+
+	var pw *columnar.Writer
+	if cfg.Parquet {
+		pw, err = columnar.Create(fname+".parquet", cfg.Header())
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	ksarray := []float64{} // store ks
 	vdarray := []float64{} // store VarD
 	ngarray := []float64{} // store generation number
 
 	// do evolution
-	for i := 0; i < ngen; i++ {
+	for i := 0; i < cfg.Gens; i++ {
 		pop.Evolve()
+		generation := i + 1
+		seqs := genomes(pop)
 		// we make 10 samples and average
 		ksmean := desc.NewMean()
 		vdmean := desc.NewMean()
-		for j := 0; j < sampleTime; j++ {
-			sample := fwd.Sample(pop.Genomes, sampleSize)
-			dmatrix := fwd.GenerateDistanceMatrix(sample)
-			cmatrix := covs.NewCMatrix(sampleSize, pop.Length, dmatrix)
+		for j := 0; j < *sampleTime; j++ {
+			drawn, err := sampler.Pairs(cfg.Size, r)
+			if err != nil {
+				log.Panic(err)
+			}
+			dmatrix := bitseq.DiffMatrix(seqs, drawn, 0)
+			cmatrix := covs.NewCMatrix(len(dmatrix), cfg.Length, dmatrix)
 			ks, vard := cmatrix.D()
 			ksmean.Increment(ks)
 			vdmean.Increment(vard)
 		}
 		// write to csv
-		f.WriteString(fmt.Sprintf("%d,%g,%g\n", pop.NumOfGen, ksmean.GetResult(), vdmean.GetResult()))
+		if _, err := fmt.Fprintf(f, "%d,%g,%g\n", generation, ksmean.GetResult(), vdmean.GetResult()); err != nil {
+			log.Panic(err)
+		}
+		if err := ev.Generation(params, 0, generation, ksmean.GetResult(), vdmean.GetResult()); err != nil {
+			log.Panic(err)
+		}
+		if pw != nil {
+			if err := pw.D(params, 0, generation, ksmean.GetResult(), vdmean.GetResult()); err != nil {
+				log.Panic(err)
+			}
+		}
 		if (i+1)%1000 == 0 {
-			fmt.Println("Generation: ", pop.NumOfGen, ksmean.GetResult(), vdmean.GetResult())
+			log.Println("Generation: ", generation, ksmean.GetResult(), vdmean.GetResult())
 		}
 
 		// store array for draw
 		ksarray = append(ksarray, ksmean.GetResult())
 		vdarray = append(vdarray, vdmean.GetResult())
-		ngarray = append(ngarray, float64(pop.NumOfGen))
+		ngarray = append(ngarray, float64(generation))
 	}
 
 	// draw
@@ -95,15 +141,22 @@ func main() {
 	svger.Plot(&pl)
 	svger.Close()
 
+	exportGenomes(&cfg, fname, 0, pop)
+	if pw != nil {
+		if err := pw.Close(); err != nil {
+			log.Panic(err)
+		}
+	}
+
 	// save population
 	jf, err := os.Create(fname + ".json")
 	if err != nil {
 		panic(err)
 	}
 	defer jf.Close()
-	b, err := json.Marshal(pop)
-	if err != nil {
-		panic(err)
+	jf.Write(pop.Json())
+
+	if err := ev.Close(); err != nil {
+		log.Panic(err)
 	}
-	jf.Write(b)
 }
@@ -0,0 +1,87 @@
+package hgtfwd
+
+import (
+	"flag"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/rng"
+	"github.com/mingzhi/gomain/window"
+	"github.com/mingzhi/hgt/fwd"
+	"log"
+	"time"
+)
+
+// Scan evolves a population and scans its genomes in sliding windows,
+// writing ks, vd and the covariance series of every window of a sample.
+func Scan(args []string) {
+	cfg := config.Config{
+		Size:     1000,
+		Length:   100000,
+		Fragment: 100,
+		MaxL:     200,
+		Gens:     10000,
+		Sample:   100,
+		Pairs:    "subset",
+		Mutation: 1e-4,
+		Transfer: 1e-4,
+		Window:   10000,
+		Prefix:   "scan",
+	}
+
+	fs := flag.NewFlagSet("fwd scan", flag.ExitOnError)
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Gens, "gens", cfg.Gens, "number of generations")
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "sample size")
+	cfg.RegisterPairsFlag(fs)
+	cfg.RegisterWindowFlag(fs)
+	cfg.RegisterStepFlag(fs)
+	cfg.RegisterExportFlags(fs)
+	cfg.RegisterParquetFlag(fs)
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Window <= 0 || cfg.Window > cfg.Length {
+		log.Fatalf("window %d must be positive and at most the genome length", cfg.Window)
+	}
+	if cfg.MaxL > cfg.Window {
+		log.Fatalf("maxl %d is larger than the window %d", cfg.MaxL, cfg.Window)
+	}
+	sampler := newSampler(&cfg)
+
+	t0 := time.Now()
+
+	pop := fwd.NewSeqPop(cfg.Size, cfg.Length, cfg.Mutation, cfg.Transfer, cfg.Fragment)
+	pop.Seed(int(rng.Seed(cfg.Seed, rng.Evolution, 0)))
+	for i := 0; i < cfg.Gens; i++ {
+		pop.Evolve()
+	}
+	exportGenomes(&cfg, cfg.Prefix, 0, pop)
+
+	r := rng.New(cfg.Seed, rng.Sampling, 0)
+	drawn, err := sampler.Pairs(cfg.Size, r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dmatrix := bitseq.DiffMatrix(genomes(pop), drawn, 0)
+
+	windows := window.Sliding(cfg.Length, cfg.Window, cfg.Step)
+	tracks := window.Scan(len(dmatrix), dmatrix, windows, cfg.MaxL, 0)
+	if err := window.WriteScan(cfg.Prefix, cfg.Header(), tracks); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Parquet {
+		pw, err := columnar.Create(cfg.Prefix+".parquet", cfg.Header())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := pw.Scan(columnar.ParamsOf(&cfg), cfg.Gens, tracks); err != nil {
+			log.Fatal(err)
+		}
+		if err := pw.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.Printf("%d windows, %v\n", len(windows), time.Since(t0))
+}
@@ -0,0 +1,218 @@
+package hgtfwd
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mingzhi/gomain/bitseq"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/events"
+	"github.com/mingzhi/gomain/registry"
+	"github.com/mingzhi/gomain/rng"
+	"github.com/mingzhi/gomain/sweep"
+	"github.com/mingzhi/hgt/covs"
+	"github.com/mingzhi/hgt/fwd"
+	"log"
+	"os"
+	"runtime"
+	"time"
+)
+
+// sweepConfig returns the default parameters of the sweeps.
+func sweepConfig() config.Config {
+	return config.Config{
+		Size:     1000,
+		Length:   1000,
+		Mutation: 0.0001,
+		Transfer: 0.0,
+		Fragment: 0,
+		Sample:   100,
+		Pairs:    "subset",
+		Gens:     100000,
+		Prefix:   "sweep",
+	}
+}
+
+// registerSweepFlags registers the flags shared by the sweeps.
+func registerSweepFlags(fs *flag.FlagSet, cfg *config.Config) {
+	cfg.RegisterFlags(fs)
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "sample size")
+	fs.IntVar(&cfg.Gens, "gens", cfg.Gens, "number of generations")
+	cfg.RegisterPairsFlag(fs)
+	cfg.RegisterExportFlags(fs)
+}
+
+// Sweep records ks and vd over time for every point of a parameter sweep.
+func Sweep(args []string) {
+	runSweep("sweep", sweepConfig(), args)
+}
+
+// SweepSize records ks and vd over time for several population sizes.
+func SweepSize(args []string) {
+	cfg := sweepConfig()
+	cfg.Sweep.Size = config.Ints{100, 1000, 10000, 100000}
+	runSweep("sweep size", cfg, args)
+}
+
+// SweepMutation records ks and vd over time for several mutation rates.
+func SweepMutation(args []string) {
+	cfg := sweepConfig()
+	cfg.Sweep.Mutation = config.Floats{0.1, 0.01, 0.001, 0.0001, 0.00001}
+	runSweep("sweep mutation", cfg, args)
+}
+
+func runSweep(name string, cfg config.Config, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	registerSweepFlags(fs, &cfg)
+	fs.Var(&cfg.Sweep.Size, "sizes", "population sizes to sweep over")
+	fs.Var(&cfg.Sweep.Length, "lengths", "genome lengths to sweep over")
+	fs.Var(&cfg.Sweep.Fragment, "frags", "fragment lengths to sweep over")
+	fs.Var(&cfg.Sweep.Mutation, "mutations", "mutation rates to sweep over")
+	fs.Var(&cfg.Sweep.Transfer, "transfers", "transfer rates to sweep over")
+	fs.StringVar(&cfg.Sweep.Mode, "mode", "grid", "grid: all combinations of the values; list: the i-th values together")
+	cfg.RegisterJobsFlag(fs)
+	cfg.RegisterParquetFlag(fs)
+	cfg.RegisterDBFlag(fs)
+	cfg.RegisterEventsFlag(fs)
+	if err := cfg.Parse(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	newSampler(&cfg) // check the pair sampling before the points start
+
+	points, err := sweep.Points(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	run, err := registry.Start(name, &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ev, err := events.Start(name, &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	t0 := time.Now() // start time
+	log.Printf("Start up at: %v\n", t0)
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	file, err := os.Create(fmt.Sprintf("%s_d.csv", cfg.Prefix))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	cfg.Header().Write(file)
+	sink, err := sweep.CSV(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sinks := []sweep.Sink{sink}
+
+	var pw *columnar.Writer
+	if cfg.Parquet {
+		pw, err = columnar.Create(cfg.Prefix+".parquet", cfg.Header())
+		if err != nil {
+			log.Fatal(err)
+		}
+		sinks = append(sinks, func(i int, p sweep.Point, rows []sweep.Row) error {
+			for _, r := range rows {
+				if err := pw.D(pointParams(p), i, r.Generation, r.KS, r.VD); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if ev != nil {
+		sinks = append(sinks, func(i int, p sweep.Point, rows []sweep.Row) error {
+			for _, r := range rows {
+				if err := ev.Generation(pointParams(p), i, r.Generation, r.KS, r.VD); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if run != nil {
+		sinks = append(sinks, func(i int, p sweep.Point, rows []sweep.Row) error {
+			ks, vd := make([]float64, len(rows)), make([]float64, len(rows))
+			for j, r := range rows {
+				ks[j], vd[j] = r.KS, r.VD
+			}
+			return run.D(pointParams(p), func(j int) (int, int) {
+				return i, rows[j].Generation
+			}, ks, vd)
+		})
+	}
+
+	err = sweep.Run(points, cfg.Jobs, func(i int, p sweep.Point) []sweep.Row {
+		return evolveD(&cfg, p, i)
+	}, sinks...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if pw != nil {
+		if err := pw.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := run.Finish(); err != nil {
+		log.Fatal(err)
+	}
+	if err := ev.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	t1 := time.Now()
+	log.Printf("End at: %v\n", t1)
+	log.Printf("Duration: %v\n", t1.Sub(t0))
+}
+
+// pointParams returns the parameter values of p attached to the result rows.
+func pointParams(p sweep.Point) columnar.Params {
+	return columnar.Params{
+		Size:     p.Size,
+		Length:   p.Length,
+		Fragment: p.Fragment,
+		Mutation: p.Mutation,
+		Transfer: p.Transfer,
+	}
+}
+
+// evolveD evolves a population with the parameters of point p for
+// cfg.Gens generations, and returns ks and vd of a random sample of
+// cfg.Sample genomes at every generation. The random streams are those
+// of replicate rep of the master seed.
+func evolveD(cfg *config.Config, p sweep.Point, rep int) []sweep.Row {
+	numofgen, seed := cfg.Gens, cfg.Seed
+	sampler := newSampler(cfg)
+	t0 := time.Now()
+	rows := make([]sweep.Row, 0, numofgen)
+	pop := fwd.NewSeqPop(p.Size, p.Length, p.Mutation, p.Transfer, p.Fragment)
+	pop.Seed(int(rng.Seed(seed, rng.Evolution, rep)))
+	r := rng.New(seed, rng.Sampling, rep)
+	for i := 0; i < numofgen; i++ {
+		pop.Evolve()
+		generation := i + 1
+		drawn, err := sampler.Pairs(p.Size, r)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		// calculate the distance matrix; the points already run in parallel
+		dmatrix := bitseq.DiffMatrix(genomes(pop), drawn, 1)
+		// create cmatrix
+		cmatrix := covs.NewCMatrix(len(dmatrix), p.Length, dmatrix)
+		// calculate ks and vard
+		ks, vd := cmatrix.D()
+		rows = append(rows, sweep.Row{Generation: generation, KS: ks, VD: vd})
+
+		// printing the process
+		if generation%1000 == 0 {
+			t2 := time.Now()
+			log.Printf("Number of Generation with %v: %d, time used = %v\n", p, generation, t2.Sub(t0))
+		}
+	}
+	exportGenomes(cfg, fmt.Sprintf("%s_point%d", cfg.Prefix, rep), rep, pop)
+	return rows
+}
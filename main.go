@@ -0,0 +1,69 @@
+// Command gomain runs the hgt simulations and analysis tools.
+//
+// Usage:
+//
+//	gomain <command> [flags]
+//
+// Use "gomain <command> -h" for the flags of a command.
+package main
+
+import (
+	"fmt"
+	"github.com/mingzhi/gomain/empirical"
+	"github.com/mingzhi/gomain/hgtcoals"
+	"github.com/mingzhi/gomain/hgtfwd"
+	"github.com/mingzhi/gomain/registry"
+	"github.com/mingzhi/gomain/utils"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name  string // command words, e.g. "fwd single"
+	run   func(args []string)
+	short string // one-line description
+}
+
+// commands lists the subcommands. A command whose name is a prefix
+// of another must come after it.
+var commands = []command{
+	{"fwd single", hgtfwd.Single, "evolve one population and sample it over time"},
+	{"fwd hpc", hgtfwd.HPC, "simulate independent forward replicates on all CPUs"},
+	{"fwd scan", hgtfwd.Scan, "ks, vd and covariances of a population in sliding windows"},
+	{"fwd cvg", hgtfwd.Cvg, "average ks and vd of several samples over time, to follow convergence"},
+	{"fwd ks", hgtfwd.KS, "record ks and vd of a population at every generation"},
+	{"coals hpc", hgtcoals.HPC, "simulate coalescent replicates on all CPUs"},
+	{"coals", hgtcoals.Run, "simulate coalescent replicates"},
+	{"sweep size", hgtfwd.SweepSize, "ks and vd for several population sizes"},
+	{"sweep mutation", hgtfwd.SweepMutation, "ks and vd for several mutation rates"},
+	{"sweep length", hgtfwd.SweepLength, "ks and vd for several prefix lengths of a genome"},
+	{"sweep", hgtfwd.Sweep, "ks and vd over a grid or list of parameter values"},
+	{"empirical", empirical.Run, "ks, vd and covariances of a real alignment"},
+	{"merge", utils.MergeShards, "merge the shards of a job array run"},
+	{"merge-covs", utils.MergeCovs, "merge _covs.csv files given as arguments, by -glob, or by -dir and -num"},
+	{"runs list", registry.List, "list the runs recorded in a database"},
+	{"runs show", registry.Show, "show a run recorded in a database"},
+	{"bench diff", hgtfwd.BenchDiff, "time the packed pairwise diff against the site loop"},
+	{"bench", hgtfwd.Bench, "time the evolution of a population"},
+}
+
+func main() {
+	args := os.Args[1:]
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == c.name {
+			c.run(args[len(words):])
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gomain <command> [flags]\n\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.name, c.short)
+	}
+}
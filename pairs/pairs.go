@@ -0,0 +1,112 @@
+// Package pairs draws the pairs of genomes whose differences are compared,
+// with one of several strategies, so that the variance of ks estimates
+// can be compared across drivers.
+package pairs
+
+import (
+	"fmt"
+	"github.com/mingzhi/gomain/bitseq"
+	"math/rand"
+)
+
+// Sampler draws pairs of genomes from a population.
+type Sampler interface {
+	// Pairs draws pairs of the genomes of a population of size n with r.
+	Pairs(n int, r *rand.Rand) ([]bitseq.Pair, error)
+}
+
+// New returns the sampler of a strategy:
+//
+//	replace   k random pairs, drawn with replacement
+//	distinct  k distinct random pairs
+//	subset    all the pairs of a random subset of k genomes
+//	all       all the pairs of the population
+func New(strategy string, k int) (Sampler, error) {
+	switch strategy {
+	case "replace":
+		return replace(k), nil
+	case "distinct":
+		return distinct(k), nil
+	case "subset":
+		return subset(k), nil
+	case "all":
+		return all{}, nil
+	}
+	return nil, fmt.Errorf("unknown pair sampling: %s", strategy)
+}
+
+type replace int
+
+// Pairs draws the genomes of every pair at random, redrawing the second
+// while it is the first.
+func (k replace) Pairs(n int, r *rand.Rand) ([]bitseq.Pair, error) {
+	if n < 2 {
+		return nil, fmt.Errorf("cannot draw pairs of %d genomes", n)
+	}
+	pairs := make([]bitseq.Pair, k)
+	for j := range pairs {
+		a := r.Intn(n)
+		b := r.Intn(n)
+		for a == b {
+			b = r.Intn(n)
+		}
+		pairs[j] = bitseq.Pair{a, b}
+	}
+	return pairs, nil
+}
+
+type distinct int
+
+// Pairs draws pairs at random and rejects those already drawn, or,
+// when k is more than half of all the pairs, takes k of them in a
+// random order.
+func (k distinct) Pairs(n int, r *rand.Rand) ([]bitseq.Pair, error) {
+	total := n * (n - 1) / 2
+	if int(k) > total {
+		return nil, fmt.Errorf("cannot draw %d distinct pairs of %d genomes", k, n)
+	}
+	if 2*int(k) > total {
+		all := bitseq.AllPairs(n)
+		pairs := make([]bitseq.Pair, k)
+		for j, i := range r.Perm(total)[:k] {
+			pairs[j] = all[i]
+		}
+		return pairs, nil
+	}
+
+	seen := make(map[bitseq.Pair]bool)
+	pairs := make([]bitseq.Pair, 0, k)
+	for len(pairs) < int(k) {
+		a := r.Intn(n)
+		b := r.Intn(n)
+		if a == b {
+			continue
+		}
+		if a > b {
+			a, b = b, a
+		}
+		p := bitseq.Pair{a, b}
+		if !seen[p] {
+			seen[p] = true
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs, nil
+}
+
+type subset int
+
+// Pairs takes the first k genomes of a random permutation.
+func (k subset) Pairs(n int, r *rand.Rand) ([]bitseq.Pair, error) {
+	if int(k) > n {
+		return nil, fmt.Errorf("cannot draw %d of %d genomes", k, n)
+	}
+	return bitseq.SubsetPairs(r.Perm(n)[:k]), nil
+}
+
+type all struct{}
+
+// Pairs returns all the pairs and does not use r.
+func (all) Pairs(n int, r *rand.Rand) ([]bitseq.Pair, error) {
+	return bitseq.AllPairs(n), nil
+}
@@ -0,0 +1,165 @@
+package pairs
+
+import (
+	"github.com/mingzhi/gomain/bitseq"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// draw returns the pairs of a strategy with a source seeded with seed.
+func draw(t *testing.T, strategy string, k, n int, seed int64) ([]bitseq.Pair, error) {
+	s, err := New(strategy, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.Pairs(n, rand.New(rand.NewSource(seed)))
+}
+
+// checkDistinct reports pairs of genomes outside [0, n), pairs of
+// a genome with itself, and pairs drawn twice in either order.
+func checkDistinct(t *testing.T, name string, pairs []bitseq.Pair, n int) {
+	seen := make(map[bitseq.Pair]bool)
+	for _, p := range pairs {
+		a, b := p[0], p[1]
+		if a < 0 || b < 0 || a >= n || b >= n || a == b {
+			t.Errorf("%s: pair %v of %d genomes", name, p, n)
+		}
+		if a > b {
+			a, b = b, a
+		}
+		if seen[bitseq.Pair{a, b}] {
+			t.Errorf("%s: pair %v drawn twice", name, p)
+		}
+		seen[bitseq.Pair{a, b}] = true
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	n := 6
+	total := n * (n - 1) / 2
+	for k := 0; k <= total; k++ {
+		for seed := int64(0); seed < 5; seed++ {
+			pairs, err := draw(t, "distinct", k, n, seed)
+			if err != nil {
+				t.Fatalf("%d of %d pairs: %v", k, total, err)
+			}
+			if len(pairs) != k {
+				t.Errorf("%d of %d pairs: drew %d", k, total, len(pairs))
+			}
+			checkDistinct(t, "distinct", pairs, n)
+		}
+	}
+	if pairs, err := draw(t, "distinct", total+1, n, 1); err == nil {
+		t.Errorf("%d of %d pairs: drew %v without error", total+1, total, pairs)
+	}
+}
+
+func TestAllPairs(t *testing.T) {
+	tests := []struct {
+		strategy string
+		k, n     int
+		genomes  int
+	}{
+		{"subset", 5, 10, 5},
+		{"subset", 10, 10, 10},
+		{"subset", 2, 3, 2},
+		{"all", 0, 7, 7},
+		{"all", 0, 2, 2},
+	}
+	for _, test := range tests {
+		pairs, err := draw(t, test.strategy, test.k, test.n, 1)
+		if err != nil {
+			t.Errorf("%s of %d of %d genomes: %v", test.strategy, test.k, test.n, err)
+			continue
+		}
+		want := test.genomes * (test.genomes - 1) / 2
+		if len(pairs) != want {
+			t.Errorf("%s of %d of %d genomes: %d pairs, want %d", test.strategy, test.k, test.n, len(pairs), want)
+		}
+		checkDistinct(t, test.strategy, pairs, test.n)
+		genomes := make(map[int]bool)
+		for _, p := range pairs {
+			genomes[p[0]], genomes[p[1]] = true, true
+		}
+		if len(genomes) != test.genomes {
+			t.Errorf("%s of %d of %d genomes: pairs of %d genomes", test.strategy, test.k, test.n, len(genomes))
+		}
+	}
+	if pairs, err := draw(t, "subset", 11, 10, 1); err == nil {
+		t.Errorf("subset of 11 of 10 genomes: drew %v without error", pairs)
+	}
+}
+
+func TestFewGenomes(t *testing.T) {
+	for _, strategy := range []string{"replace", "distinct", "subset", "all"} {
+		for _, n := range []int{0, 1} {
+			for _, k := range []int{0, 1, 5} {
+				pairs, err := draw(t, strategy, k, n, 1)
+				if err == nil && len(pairs) != 0 {
+					t.Errorf("%s of %d with %d genomes: drew %v", strategy, k, n, pairs)
+				}
+			}
+		}
+	}
+	if _, err := draw(t, "replace", 5, 1, 1); err == nil {
+		t.Error("replace with 1 genome: drew pairs without error")
+	}
+}
+
+// The pairs the drivers drew before the strategies, from the same numbers.
+func legacyReplace(size, sample int, r *rand.Rand) []bitseq.Pair {
+	pairs := make([]bitseq.Pair, sample)
+	for j := range pairs {
+		a := r.Intn(size)
+		b := r.Intn(size)
+		for a == b {
+			b = r.Intn(size)
+		}
+		pairs[j] = bitseq.Pair{a, b}
+	}
+	return pairs
+}
+
+func legacySubset(size, sample int, r *rand.Rand) []bitseq.Pair {
+	rSeq := r.Perm(size)
+	return bitseq.SubsetPairs(rSeq[:sample])
+}
+
+func TestUnchanged(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		for _, size := range []int{2, 3, 10, 100} {
+			sample := size / 2
+			if sample < 2 {
+				sample = 2
+			}
+			got, err := draw(t, "replace", sample, size, seed)
+			want := legacyReplace(size, sample, rand.New(rand.NewSource(seed)))
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("replace %d of %d, seed %d: %v, %v, want %v", sample, size, seed, got, err, want)
+			}
+
+			got, err = draw(t, "subset", sample, size, seed)
+			want = legacySubset(size, sample, rand.New(rand.NewSource(seed)))
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("subset %d of %d, seed %d: %v, %v, want %v", sample, size, seed, got, err, want)
+			}
+		}
+	}
+
+	// and the sampler keeps drawing from the source where the legacy code did
+	r1, r2 := rand.New(rand.NewSource(1)), rand.New(rand.NewSource(1))
+	s, _ := New("replace", 20)
+	for i := 0; i < 3; i++ {
+		got, _ := s.Pairs(10, r1)
+		if want := legacyReplace(10, 20, r2); !reflect.DeepEqual(got, want) {
+			t.Errorf("draw %d from one source: %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("bootstrap", 10); err == nil {
+		t.Error("unknown strategy: no error")
+	}
+}
@@ -0,0 +1,231 @@
+// Package registry records runs and their results in an SQLite database,
+// so that the parameters, seed, code revision and host of every result
+// can be looked up later.
+package registry
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/mingzhi/gomain/columnar"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/stats"
+	"math"
+	_ "modernc.org/sqlite"
+	"time"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id       INTEGER PRIMARY KEY,
+	command  TEXT,
+	prefix   TEXT,
+	config   TEXT,
+	seed     INTEGER,
+	revision TEXT,
+	host     TEXT,
+	started  TEXT,
+	finished TEXT
+);
+CREATE TABLE IF NOT EXISTS d (
+	run        INTEGER REFERENCES runs(id),
+	size       INTEGER,
+	length     INTEGER,
+	fragment   INTEGER,
+	mutation   REAL,
+	transfer   REAL,
+	replicate  INTEGER,
+	generation INTEGER,
+	ks         REAL,
+	vd         REAL
+);
+CREATE TABLE IF NOT EXISTS covs (
+	run    INTEGER REFERENCES runs(id),
+	series TEXT,
+	dist   INTEGER,
+	mean   REAL,
+	sd     REAL,
+	se     REAL,
+	n      INTEGER
+);
+`
+
+// Run is a run being recorded.
+type Run struct {
+	db *sql.DB
+	id int64
+}
+
+// Open opens the database in filename, creating its tables if needed.
+func Open(filename string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Start records the start of a run of command with cfg in the database
+// cfg.DB. It returns nil if no database is set.
+func Start(command string, cfg *config.Config) (*Run, error) {
+	if cfg.DB == "" {
+		return nil, nil
+	}
+	db, err := Open(cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	p := config.CurrentProvenance()
+	res, err := db.Exec(`INSERT INTO runs (command, prefix, config, seed, revision, host, started) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		command, cfg.Prefix, string(data), cfg.Seed, p.Revision, p.Host, now())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Run{db: db, id: id}, nil
+}
+
+// Resume continues the run id of the database cfg.DB, recorded before a
+// checkpoint, by clearing its end. If id is 0 it starts a new run of
+// command, see Start.
+func Resume(command string, cfg *config.Config, id int64) (*Run, error) {
+	if cfg.DB == "" || id == 0 {
+		return Start(command, cfg)
+	}
+	db, err := Open(cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.Exec(`UPDATE runs SET finished = NULL WHERE id = ?`, id)
+	if err == nil {
+		var n int64
+		n, err = res.RowsAffected()
+		if err == nil && n == 0 {
+			err = fmt.Errorf("%s: no run %d to resume", cfg.DB, id)
+		}
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Run{db: db, id: id}, nil
+}
+
+// ID returns the id of the run, or 0 if r is nil.
+func (r *Run) ID() int64 {
+	if r == nil {
+		return 0
+	}
+	return r.id
+}
+
+func now() string {
+	return time.Now().Format(time.RFC3339)
+}
+
+// D records ks and vd of replicates. index gives the replicate and
+// the generation of the i-th value.
+func (r *Run) D(p columnar.Params, index func(i int) (replicate, generation int), ks, vd []float64) error {
+	if r == nil {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO d VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for i := range ks {
+		replicate, generation := index(i)
+		_, err := stmt.Exec(r.id, p.Size, p.Length, p.Fragment, p.Mutation, p.Transfer, replicate, generation, ks[i], vd[i])
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Covs records the covariance curves.
+func (r *Run) Covs(c *stats.Covs) error {
+	if r == nil {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT INTO covs VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for i, name := range stats.CovNames {
+		for l := 0; l < c.MaxL(); l++ {
+			m := &c.Series[i][l]
+			if _, err := stmt.Exec(r.id, name, l, m.Mean, nullable(m.Sd()), nullable(m.Se()), m.N); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// nullable stores an undefined value, such as the SD of one replicate, as NULL.
+func nullable(x float64) interface{} {
+	if math.IsNaN(x) {
+		return nil
+	}
+	return x
+}
+
+// Save records the rows of the _d.csv file dname and the covariance
+// curves of a run, and its end. index gives the replicate and the
+// generation of the i-th row of the d file.
+func (r *Run) Save(cfg *config.Config, dname string, index func(i int) (replicate, generation int), c *stats.Covs) error {
+	if r == nil {
+		return nil
+	}
+	_, ks, vd, err := results.ReadD(dname)
+	if err != nil {
+		return err
+	}
+	if err := r.D(columnar.ParamsOf(cfg), index, ks, vd); err != nil {
+		return err
+	}
+	if err := r.Covs(c); err != nil {
+		return err
+	}
+	return r.Finish()
+}
+
+// Finish records the end of the run and closes the database.
+func (r *Run) Finish() error {
+	if r == nil {
+		return nil
+	}
+	defer r.db.Close()
+	_, err := r.db.Exec(`UPDATE runs SET finished = ? WHERE id = ?`, now(), r.id)
+	return err
+}
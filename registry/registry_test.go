@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"github.com/mingzhi/gomain/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{DB: filepath.Join(dir, "runs.db"), Prefix: "test"}
+
+	run, err := Start("fwd hpc", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := run.ID()
+	if err := run.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Resume = true
+	run, err = Resume("fwd hpc", cfg, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID() != id {
+		t.Errorf("resumed run %d, want %d", run.ID(), id)
+	}
+	if err := run.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(cfg.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM runs`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d runs recorded, want 1", n)
+	}
+
+	if _, err := Resume("fwd hpc", cfg, id+1); err == nil {
+		t.Error("resumed a run that is not in the database")
+	}
+	run, err = Resume("fwd hpc", cfg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.ID() == id {
+		t.Error("resume without a run id did not start a new run")
+	}
+	run.Finish()
+}
@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// List prints the runs recorded in a database.
+func List(args []string) {
+	fs := flag.NewFlagSet("runs list", flag.ExitOnError)
+	dbname := fs.String("db", "runs.db", "database of the runs")
+	fs.Parse(args)
+
+	db, err := Open(*dbname)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, command, prefix, seed, started, COALESCE(finished, '') FROM runs ORDER BY id`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCOMMAND\tPREFIX\tSEED\tSTARTED\tFINISHED")
+	for rows.Next() {
+		var id, seed int64
+		var command, prefix, started, finished string
+		if err := rows.Scan(&id, &command, &prefix, &seed, &started, &finished); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", id, command, prefix, seed, started, finished)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+	w.Flush()
+}
+
+// Show prints a run recorded in a database: its provenance,
+// its parameters and the number of rows of its results.
+func Show(args []string) {
+	fs := flag.NewFlagSet("runs show", flag.ExitOnError)
+	dbname := fs.String("db", "runs.db", "database of the runs")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: gomain runs show [-db file] <id>")
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		log.Fatalf("bad run id: %s", fs.Arg(0))
+	}
+
+	db, err := Open(*dbname)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	var command, prefix, cfg, revision, host, started, finished string
+	var seed int64
+	err = db.QueryRow(`SELECT command, prefix, config, seed, revision, host, started, COALESCE(finished, '') FROM runs WHERE id = ?`, id).
+		Scan(&command, &prefix, &cfg, &seed, &revision, &host, &started, &finished)
+	if err == sql.ErrNoRows {
+		log.Fatalf("no run %d in %s", id, *dbname)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var nd, ncovs int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM d WHERE run = ?`, id).Scan(&nd); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM covs WHERE run = ?`, id).Scan(&ncovs); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("id: %d\ncommand: %s\nprefix: %s\nseed: %d\nrevision: %s\nhost: %s\nstarted: %s\nfinished: %s\n",
+		id, command, prefix, seed, revision, host, started, finished)
+	fmt.Printf("d rows: %d\ncovs rows: %d\n", nd, ncovs)
+
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(cfg), &params); err != nil {
+		log.Fatal(err)
+	}
+	out, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("config: %s\n", out)
+}
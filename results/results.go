@@ -0,0 +1,311 @@
+// Package results writes the _d.csv and _covs.csv files
+// produced by the simulation drivers.
+package results
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"github.com/mingzhi/gomain/stats"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ProgressStep returns the number of replicates between the intermediate
+// writes of the results, one for every percent of n.
+func ProgressStep(n int) int {
+	if n < 100 {
+		return 1
+	}
+	return n / 100
+}
+
+// Header is an ordered list of "#key: value" lines.
+type Header struct {
+	keys   []string
+	values []interface{}
+
+	// Columns holds the column names of a file read by ReadHeader.
+	Columns []string
+}
+
+// Add appends a key and its value to the header.
+func (h *Header) Add(key string, value interface{}) {
+	h.keys = append(h.keys, key)
+	h.values = append(h.values, value)
+}
+
+// Get returns the value of key formatted as in the header.
+func (h *Header) Get(key string) (string, bool) {
+	for i, k := range h.keys {
+		if k == key {
+			return fmt.Sprint(h.values[i]), true
+		}
+	}
+	return "", false
+}
+
+// Keys returns the keys of the header in order.
+func (h *Header) Keys() []string {
+	return h.keys
+}
+
+// ReadHeader reads the "#key: value" lines at the start of r.
+// A comment line without a colon holds the column names.
+// The values are kept as strings.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	h := &Header{}
+	for {
+		b, err := r.Peek(1)
+		if err != nil || b[0] != '#' {
+			if err == io.EOF {
+				err = nil
+			}
+			return h, err
+		}
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return h, err
+		}
+		line = strings.TrimSpace(line[1:])
+		if i := strings.Index(line, ": "); i > 0 {
+			h.Add(line[:i], line[i+2:])
+		} else {
+			h.Columns = strings.Split(line, ", ")
+		}
+	}
+}
+
+// Write writes the header lines to w.
+func (h *Header) Write(w io.Writer) error {
+	for i, key := range h.keys {
+		if _, err := fmt.Fprintf(w, "#%s: %v\n", key, h.values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateD creates a _d.csv file and writes the header into it.
+func CreateD(filename string, h *Header) (*os.File, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Write(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteString("#ks, vd\n"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// WriteD writes one row of ks and vd.
+func WriteD(w io.Writer, ks, vd float64) error {
+	_, err := fmt.Fprintf(w, "%g,%g\n", ks, vd)
+	return err
+}
+
+// ReadD reads the header and the ks and vd rows of a _d.csv file.
+func ReadD(filename string) (h *Header, ks, vd []float64, err error) {
+	h, records, err := readTable(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ks = make([]float64, len(records))
+	vd = make([]float64, len(records))
+	for i, rec := range records {
+		if len(rec) != 2 {
+			return nil, nil, nil, fmt.Errorf("%s: bad number of columns in row %d", filename, i)
+		}
+		ks[i], err = strconv.ParseFloat(rec[0], 64)
+		if err == nil {
+			vd[i], err = strconv.ParseFloat(rec[1], 64)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return h, ks, vd, nil
+}
+
+// covsFields are the columns of a series in a _covs.csv file:
+// mean, standard deviation, standard error and number of replicates.
+var covsFields = []string{"_mean", "_sd", "_se", "_n"}
+
+// WriteCovs writes the header and, for every distance, the mean, standard
+// deviation, standard error and number of replicates of every covariance
+// series to filename.
+func WriteCovs(filename string, h *Header, c *stats.Covs) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h.Write(f)
+	columns := []string{"dist"}
+	for _, name := range stats.CovNames {
+		for _, field := range covsFields {
+			columns = append(columns, name+field)
+		}
+	}
+	f.WriteString("#" + strings.Join(columns, ", ") + "\n")
+
+	for l := 0; l < c.MaxL(); l++ {
+		fields := []string{strconv.Itoa(l)}
+		for i := range c.Series {
+			m := &c.Series[i][l]
+			fields = append(fields, fmt.Sprintf("%g", m.Mean),
+				fmt.Sprintf("%g", m.Sd()), fmt.Sprintf("%g", m.Se()), strconv.Itoa(m.N))
+		}
+		if _, err := fmt.Fprintln(f, strings.Join(fields, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadCovs reads a file written by WriteCovs and recovers the moments of its
+// covariance series from the counts, means and standard deviations.
+// The ranges of the values are not in the file and are left unknown (NaN).
+// Files of other layouts, such as the mean and _sd columns of the old
+// drivers, are refused.
+func ReadCovs(filename string) (*Header, *stats.Covs, error) {
+	h, records, err := readTable(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := map[string]int{}
+	for i, name := range h.Columns {
+		index[name] = i
+	}
+
+	c := stats.NewCovs(len(records))
+	for i, name := range stats.CovNames {
+		mcol, mok := index[name+"_mean"]
+		scol, sok := index[name+"_sd"]
+		ncol, nok := index[name+"_n"]
+		if !mok || !sok || !nok {
+			return nil, nil, fmt.Errorf("%s: unsupported layout, no %s_mean, %s_sd and %s_n columns", filename, name, name, name)
+		}
+
+		for l, rec := range records {
+			if len(rec) != len(h.Columns) {
+				return nil, nil, fmt.Errorf("%s: bad number of columns at distance %d", filename, l)
+			}
+			m := &c.Series[i][l]
+			var sd float64
+			m.N, err = strconv.Atoi(rec[ncol])
+			if err == nil {
+				m.Mean, err = strconv.ParseFloat(rec[mcol], 64)
+			}
+			if err == nil {
+				sd, err = strconv.ParseFloat(rec[scol], 64)
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", filename, err)
+			}
+			if m.N > 1 {
+				m.M2 = sd * sd * float64(m.N-1)
+			}
+			m.Min, m.Max = math.NaN(), math.NaN()
+		}
+	}
+	return h, c, nil
+}
+
+// momentFields are the columns of a series in a _moments.csv file.
+var momentFields = []string{"_n", "_mean", "_m2", "_min", "_max"}
+
+// WriteMoments writes the count, mean, sum of squared deviations and range
+// of every covariance moment to filename, so that partial results can be
+// merged exactly.
+func WriteMoments(filename string, h *Header, c *stats.Covs) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h.Write(f)
+	columns := []string{"dist"}
+	for _, name := range stats.CovNames {
+		for _, field := range momentFields {
+			columns = append(columns, name+field)
+		}
+	}
+	f.WriteString("#" + strings.Join(columns, ", ") + "\n")
+
+	for l := 0; l < c.MaxL(); l++ {
+		fields := []string{strconv.Itoa(l)}
+		for i := range c.Series {
+			m := c.Series[i][l]
+			fields = append(fields, strconv.Itoa(m.N),
+				fmt.Sprintf("%g", m.Mean), fmt.Sprintf("%g", m.M2),
+				fmt.Sprintf("%g", m.Min), fmt.Sprintf("%g", m.Max))
+		}
+		if _, err := fmt.Fprintln(f, strings.Join(fields, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadMoments reads a file written by WriteMoments.
+func ReadMoments(filename string) (*Header, *stats.Covs, error) {
+	h, records, err := readTable(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k := len(momentFields)
+	c := stats.NewCovs(len(records))
+	for l, rec := range records {
+		if len(rec) != 1+k*stats.NumCovs {
+			return nil, nil, fmt.Errorf("%s: bad number of columns at distance %d", filename, l)
+		}
+		for i := 0; i < stats.NumCovs; i++ {
+			m := &c.Series[i][l]
+			m.N, err = strconv.Atoi(rec[1+k*i])
+			values := []*float64{&m.Mean, &m.M2, &m.Min, &m.Max}
+			for j := 0; err == nil && j < len(values); j++ {
+				*values[j], err = strconv.ParseFloat(rec[2+k*i+j], 64)
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", filename, err)
+			}
+		}
+	}
+	return h, c, nil
+}
+
+// readTable reads the header and the data rows of a csv file.
+func readTable(filename string) (*Header, [][]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	h, err := ReadHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return h, records, nil
+}
@@ -0,0 +1,97 @@
+package results
+
+import (
+	"github.com/mingzhi/gomain/stats"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func tempFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	filename := filepath.Join(dir, name)
+	if content != "" {
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filename
+}
+
+func TestCovsRoundTrip(t *testing.T) {
+	c := stats.NewCovs(3)
+	for r := 0; r < 5; r++ {
+		row := func(k float64) []float64 {
+			return []float64{k * float64(r), k + float64(r*r), k, -k * float64(r), 0.5 * k}
+		}
+		c.Increment(row(1), row(2), row(3), row(4), row(5))
+	}
+
+	h := &Header{}
+	h.Add("size", 100)
+	h.Add("replicates", c.N())
+	filename := tempFile(t, "x_covs.csv", "")
+	if err := WriteCovs(filename, h, c); err != nil {
+		t.Fatal(err)
+	}
+
+	h2, c2, err := ReadCovs(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := h2.Get("size"); v != "100" {
+		t.Errorf("size = %q, want 100", v)
+	}
+	if c2.MaxL() != c.MaxL() {
+		t.Fatalf("maxl = %d, want %d", c2.MaxL(), c.MaxL())
+	}
+	for i := range c.Series {
+		for l := range c.Series[i] {
+			want, got := c.Series[i][l], c2.Series[i][l]
+			if got.N != want.N || !closeTo(got.Mean, want.Mean) || !closeTo(got.M2, want.M2) {
+				t.Errorf("%s[%d] = %+v, want %+v", stats.CovNames[i], l, got, want)
+			}
+			if !math.IsNaN(got.Min) || !math.IsNaN(got.Max) {
+				t.Errorf("%s[%d] range = %g, %g, want NaN", stats.CovNames[i], l, got.Min, got.Max)
+			}
+		}
+	}
+}
+
+// The layouts written by the drivers before WriteCovs.
+var legacyCovs = map[string]string{
+	"hpc": "#size: 100\n#replicates: 2\n" +
+		"#dist, scov, rcov, xy, xsys, smxy_sd, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n" +
+		"0,1,1,1,1,1,0.1,0.1,0.1,0.1,0.1\n",
+	"coals": "#size: 100\n#repeats: 2\n" +
+		"#dist, scov, rcov, xy, xsys, smxy, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n" +
+		"0,1,1,1,1,1,0.1,0.1,0.1,0.1,0.1\n",
+	"single": "#size: 100\n#generations: 2\n" +
+		"dist, scov, rcov, xy, xsys, smxy_sd, scov_sd, rcov_sd, xy_sd, xsys_sd, smxy_sd\n" +
+		"0,1,1,1,1,1,0.1,0.1,0.1,0.1,0.1\n",
+}
+
+func TestReadCovsLegacy(t *testing.T) {
+	for name, content := range legacyCovs {
+		filename := tempFile(t, name+"_covs.csv", content)
+		_, _, err := ReadCovs(filename)
+		if err == nil {
+			t.Errorf("%s: read a legacy layout without error", name)
+			continue
+		}
+		if !strings.Contains(err.Error(), "unsupported layout") {
+			t.Errorf("%s: error %q does not name the layout", name, err)
+		}
+	}
+}
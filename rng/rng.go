@@ -0,0 +1,36 @@
+// Package rng derives independent, reproducible random streams
+// from a master seed, one per replicate and purpose.
+package rng
+
+import (
+	"math/rand"
+)
+
+// Streams of a replicate.
+const (
+	Evolution = iota // evolution of the population
+	Sampling         // choice of the sampled genomes or pairs
+	Export           // choice of the exported genomes
+)
+
+// Seed returns the seed of a stream of replicate i, derived from master.
+// The seeds of different replicates and streams are mixed with SplitMix64,
+// so that neighbouring indices give unrelated streams.
+func Seed(master int64, stream, i int) int64 {
+	z := mix(uint64(master))
+	z = mix(z ^ uint64(stream))
+	z = mix(z ^ uint64(i))
+	return int64(z >> 1)
+}
+
+// New returns a random source for a stream of replicate i.
+func New(master int64, stream, i int) *rand.Rand {
+	return rand.New(rand.NewSource(Seed(master, stream, i)))
+}
+
+func mix(z uint64) uint64 {
+	z += 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,78 @@
+package rng
+
+import (
+	"fmt"
+	"math/bits"
+	"testing"
+)
+
+// The seeds of seeded runs must not change between versions, or
+// reruns of a published result would differ.
+func TestSeedFixed(t *testing.T) {
+	tests := []struct {
+		master    int64
+		stream, i int
+		seed      int64
+		first     int64
+	}{
+		{0, Evolution, 0, 1279368494785126216, 6793845381521759937},
+		{1, Sampling, 0, 3150992677718134148, 2018275433210493440},
+		{1, Export, 5, 3851293329796251419, 1950282178448375850},
+		{42, Evolution, 999, 5779368978012088979, 7688467889286065284},
+	}
+	for _, test := range tests {
+		if s := Seed(test.master, test.stream, test.i); s != test.seed {
+			t.Errorf("Seed(%d, %d, %d) = %d, want %d", test.master, test.stream, test.i, s, test.seed)
+		}
+		if x := New(test.master, test.stream, test.i).Int63(); x != test.first {
+			t.Errorf("New(%d, %d, %d) first value %d, want %d", test.master, test.stream, test.i, x, test.first)
+		}
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	for _, stream := range []int{Evolution, Sampling, Export} {
+		a, b := New(7, stream, 3), New(7, stream, 3)
+		for k := 0; k < 100; k++ {
+			if x, y := a.Int63(), b.Int63(); x != y {
+				t.Fatalf("stream %d: value %d differs: %d and %d", stream, k, x, y)
+			}
+		}
+	}
+}
+
+func TestIndependent(t *testing.T) {
+	// no two streams of nearby seeds and replicates share a seed
+	seen := map[int64]string{}
+	for master := int64(0); master < 4; master++ {
+		for _, stream := range []int{Evolution, Sampling, Export} {
+			for i := 0; i < 100; i++ {
+				s := Seed(master, stream, i)
+				key := fmt.Sprintf("%d/%d/%d", master, stream, i)
+				if other, ok := seen[s]; ok {
+					t.Fatalf("seed %d of %q is also that of %q", s, key, other)
+				}
+				seen[s] = key
+			}
+		}
+	}
+
+	// drawing from one stream leaves the others unchanged
+	want := New(1, Sampling, 0).Int63()
+	export := New(1, Export, 0)
+	for k := 0; k < 1000; k++ {
+		export.Int63()
+	}
+	if got := New(1, Sampling, 0).Int63(); got != want {
+		t.Errorf("sampling stream changed after drawing from the export stream: %d, want %d", got, want)
+	}
+
+	// the first values of the three streams of a replicate are not correlated
+	// in an obvious way: they differ in about half of their bits
+	for i := 0; i < 100; i++ {
+		a, b := New(1, Evolution, i).Int63(), New(1, Sampling, i).Int63()
+		if d := bits.OnesCount64(uint64(a ^ b)); d < 10 || d > 53 {
+			t.Errorf("replicate %d: evolution and sampling streams differ in %d of 63 bits", i, d)
+		}
+	}
+}
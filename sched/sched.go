@@ -0,0 +1,94 @@
+// Package sched runs independent tasks, such as replicates
+// or sweep points, on a bounded number of goroutines.
+package sched
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Jobs returns the number of goroutines to use for a -jobs value:
+// jobs itself if positive, the number of CPUs otherwise.
+func Jobs(jobs int) int {
+	if jobs < 1 {
+		return runtime.NumCPU()
+	}
+	return jobs
+}
+
+// Run calls fn(i) for every i in [0, n) on at most Jobs(jobs) goroutines,
+// and returns when all calls are done. The goroutines take the next index
+// from a shared queue whenever they are free, so tasks of uneven length
+// do not leave any of them idle.
+func Run(n, jobs int, fn func(i int)) {
+	jobs = Jobs(jobs)
+	if jobs > n {
+		jobs = n
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// RunOrdered calls fn(i) for every i in [start, n) like Run, and passes
+// the results to collect on the calling goroutine in index order,
+// whatever order the calls finish in. Results that finish early wait
+// in a buffer until all the results before them are collected.
+// If collect returns an error, the calls not yet started are skipped,
+// no more results are collected, and RunOrdered returns the error.
+func RunOrdered(start, n, jobs int, fn func(i int) interface{}, collect func(i int, v interface{}) error) error {
+	type result struct {
+		index int
+		value interface{}
+	}
+
+	var stop int32
+	ch := make(chan result, Jobs(jobs))
+	go func() {
+		Run(n-start, jobs, func(i int) {
+			if atomic.LoadInt32(&stop) == 0 {
+				ch <- result{start + i, fn(start + i)}
+			}
+		})
+		close(ch)
+	}()
+
+	var err error
+	pending := make(map[int]interface{})
+	next := start
+	for r := range ch {
+		if err != nil {
+			continue // drain the calls already started
+		}
+		pending[r.index] = r.value
+		for {
+			v, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if err = collect(next, v); err != nil {
+				atomic.StoreInt32(&stop, 1)
+				break
+			}
+			next++
+		}
+	}
+	return err
+}
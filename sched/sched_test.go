@@ -0,0 +1,77 @@
+package sched
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	for _, jobs := range []int{1, 3, 0, 100} {
+		var calls [50]int32
+		Run(len(calls), jobs, func(i int) {
+			atomic.AddInt32(&calls[i], 1)
+		})
+		for i, n := range calls {
+			if n != 1 {
+				t.Errorf("%d jobs: task %d called %d times", jobs, i, n)
+			}
+		}
+	}
+}
+
+func TestRunOrdered(t *testing.T) {
+	for _, jobs := range []int{1, 4, 0} {
+		order := []int{}
+		err := RunOrdered(3, 20, jobs, func(i int) interface{} {
+			// later tasks finish first
+			time.Sleep(time.Duration(20-i) * 100 * time.Microsecond)
+			return i * i
+		}, func(i int, v interface{}) error {
+			if v.(int) != i*i {
+				t.Errorf("%d jobs: task %d collected with the value %v", jobs, i, v)
+			}
+			order = append(order, i)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []int{}
+		for i := 3; i < 20; i++ {
+			want = append(want, i)
+		}
+		if !reflect.DeepEqual(order, want) {
+			t.Errorf("%d jobs: collected %v, want %v", jobs, order, want)
+		}
+	}
+}
+
+func TestRunOrderedError(t *testing.T) {
+	errStop := errors.New("disk full")
+	for _, jobs := range []int{1, 4} {
+		var started int32
+		order := []int{}
+		err := RunOrdered(0, 1000, jobs, func(i int) interface{} {
+			atomic.AddInt32(&started, 1)
+			return i
+		}, func(i int, v interface{}) error {
+			order = append(order, i)
+			if i == 5 {
+				return errStop
+			}
+			return nil
+		})
+		if err != errStop {
+			t.Errorf("%d jobs: error %v, want %v", jobs, err, errStop)
+		}
+		if !reflect.DeepEqual(order, []int{0, 1, 2, 3, 4, 5}) {
+			t.Errorf("%d jobs: collected %v after the error", jobs, order)
+		}
+		if n := atomic.LoadInt32(&started); n == 1000 {
+			t.Errorf("%d jobs: all %d tasks ran after the error", jobs, n)
+		}
+	}
+}
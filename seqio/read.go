@@ -0,0 +1,118 @@
+package seqio
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadAlignment reads a multiple sequence alignment from filename.
+// A file starting with '>' is read as FASTA, any other as relaxed
+// sequential PHYLIP. The sequences are upper cased.
+func ReadAlignment(filename string) (names []string, seqs [][]byte, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if b[0] == '>' {
+		names, seqs, err = ReadFASTA(r)
+	} else {
+		names, seqs, err = ReadPHYLIP(r)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	for i, s := range seqs {
+		if len(s) != len(seqs[0]) {
+			return nil, nil, fmt.Errorf("%s: %s has length %d, %s has %d", filename, names[i], len(s), names[0], len(seqs[0]))
+		}
+	}
+	return names, seqs, nil
+}
+
+// ReadFASTA reads the sequences of a FASTA file.
+func ReadFASTA(r io.Reader) (names []string, seqs [][]byte, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == '>' {
+			name := ""
+			if fields := strings.Fields(string(line[1:])); len(fields) > 0 {
+				name = fields[0]
+			}
+			names = append(names, name)
+			seqs = append(seqs, []byte{})
+			continue
+		}
+		if len(seqs) == 0 {
+			return nil, nil, fmt.Errorf("sequence before the first name")
+		}
+		i := len(seqs) - 1
+		seqs[i] = append(seqs[i], bytes.ToUpper(line)...)
+	}
+	return names, seqs, scanner.Err()
+}
+
+// ReadPHYLIP reads the sequences of a sequential PHYLIP file whose names
+// are separated from the sequences by white space.
+// A sequence may be wrapped over several lines.
+func ReadPHYLIP(r io.Reader) (names []string, seqs [][]byte, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024*1024)
+	scanner.Split(bufio.ScanWords)
+
+	next := func() (string, error) {
+		if !scanner.Scan() {
+			if scanner.Err() != nil {
+				return "", scanner.Err()
+			}
+			return "", io.ErrUnexpectedEOF
+		}
+		return scanner.Text(), nil
+	}
+	counts := make([]int, 2)
+	for i := range counts {
+		s, err := next()
+		if err == nil {
+			counts[i], err = strconv.Atoi(s)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad PHYLIP header: %v", err)
+		}
+	}
+
+	n, length := counts[0], counts[1]
+	for i := 0; i < n; i++ {
+		name, err := next()
+		if err != nil {
+			return nil, nil, err
+		}
+		seq := make([]byte, 0, length)
+		for len(seq) < length {
+			s, err := next()
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", name, err)
+			}
+			seq = append(seq, strings.ToUpper(s)...)
+		}
+		names = append(names, name)
+		seqs = append(seqs, seq)
+	}
+	return names, seqs, nil
+}
@@ -0,0 +1,97 @@
+package seqio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	seqs := []string{"ACGTAC", "ACGAAC", "TCGNA-"}
+	tests := []struct {
+		name  string
+		read  func(io.Reader) ([]string, [][]byte, error)
+		input string
+	}{
+		{"fasta", ReadFASTA, ">a first\nACG\ntac\n\n>b\nacgaac\n>c\nTCG\nNA-\n"},
+		{"phylip", ReadPHYLIP, "3 6\na ACG TAC\nb\tacgaac\nc TCGN\nA-\n"},
+	}
+	for _, test := range tests {
+		gotNames, gotSeqs, err := test.read(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if fmt.Sprint(gotNames) != fmt.Sprint(names) || fmt.Sprintf("%s", gotSeqs) != fmt.Sprint(seqs) {
+			t.Errorf("%s: read %v %s, want %v %v", test.name, gotNames, gotSeqs, names, seqs)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		read  func(io.Reader) ([]string, [][]byte, error)
+		input string
+	}{
+		{"fasta without a name", ReadFASTA, "ACGT\n>a\nACGT\n"},
+		{"phylip without a header", ReadPHYLIP, "a ACGT\n"},
+		{"phylip with too few sequences", ReadPHYLIP, "2 4\na ACGT\n"},
+		{"phylip with a short sequence", ReadPHYLIP, "2 4\na ACGT\nb AC\n"},
+	}
+	for _, test := range tests {
+		if names, _, err := test.read(strings.NewReader(test.input)); err == nil {
+			t.Errorf("%s: read %v without error", test.name, names)
+		}
+	}
+}
+
+func TestReadAlignment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seqio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the writers' output reads back as the bases of the genomes
+	want := "[ACGT AGGA TTGA]"
+	writers := map[string]func(io.Writer, []string, [][]byte) error{
+		"fasta":  WriteFASTA,
+		"phylip": WritePHYLIP,
+	}
+	for format, write := range writers {
+		var buf bytes.Buffer
+		if err := write(&buf, Names(3), testSeqs()); err != nil {
+			t.Fatal(err)
+		}
+		filename := filepath.Join(dir, "x"+Formats[format])
+		if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		names, seqs, err := ReadAlignment(filename)
+		if err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		if fmt.Sprint(names) != fmt.Sprint(Names(3)) || fmt.Sprintf("%s", seqs) != want {
+			t.Errorf("%s: read back %v %s, want %v %s", format, names, seqs, Names(3), want)
+		}
+	}
+
+	filename := filepath.Join(dir, "ragged.fasta")
+	if err := ioutil.WriteFile(filename, []byte(">a\nACGT\n>b\nACG\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReadAlignment(filename); err == nil {
+		t.Error("read sequences of different lengths without error")
+	}
+	if _, _, err := ReadAlignment(filepath.Join(dir, "missing.fasta")); err == nil {
+		t.Error("read a missing file without error")
+	}
+}
@@ -0,0 +1,275 @@
+// Package seqio writes sampled genomes in formats read by phylogenetics
+// and population genetics tools: FASTA, PHYLIP, Hudson's ms output and VCF.
+package seqio
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/rng"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// Formats are the export formats and the extensions of their files.
+var Formats = map[string]string{
+	"fasta":  ".fasta",
+	"phylip": ".phy",
+	"ms":     ".ms",
+	"vcf":    ".vcf",
+}
+
+// Names returns the names of n sequences.
+func Names(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("seq%d", i)
+	}
+	return names
+}
+
+// base returns the nucleotide of a genome state in upper case.
+// The codes 0 to 3 stand for A, C, G and T.
+func base(a byte) (byte, bool) {
+	switch a {
+	case 'A', 'C', 'G', 'T':
+		return a, true
+	case 'a', 'c', 'g', 't':
+		return a - 'a' + 'A', true
+	case 0, 1, 2, 3:
+		return "ACGT"[a], true
+	}
+	return 0, false
+}
+
+// bases returns the sequences with their states written as nucleotides
+// by base, or an error naming the first state that is not one.
+func bases(seqs [][]byte) ([][]byte, error) {
+	out := make([][]byte, len(seqs))
+	for i, s := range seqs {
+		out[i] = make([]byte, len(s))
+		for k, a := range s {
+			b, ok := base(a)
+			if !ok {
+				return nil, fmt.Errorf("sequence %d, site %d: state %d is not a base", i, k+1, a)
+			}
+			out[i][k] = b
+		}
+	}
+	return out, nil
+}
+
+// WriteFASTA writes the sequences to w in FASTA format.
+// Genome states are written as bases; any other state is an error.
+func WriteFASTA(w io.Writer, names []string, seqs [][]byte) error {
+	seqs, err := bases(seqs)
+	if err != nil {
+		return err
+	}
+	for i, s := range seqs {
+		if _, err := fmt.Fprintf(w, ">%s\n%s\n", names[i], s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePHYLIP writes the sequences to w in sequential PHYLIP format,
+// with names padded to ten characters.
+// Genome states are written as bases; any other state is an error.
+func WritePHYLIP(w io.Writer, names []string, seqs [][]byte) error {
+	seqs, err := bases(seqs)
+	if err != nil {
+		return err
+	}
+	length := 0
+	if len(seqs) > 0 {
+		length = len(seqs[0])
+	}
+	if _, err := fmt.Fprintf(w, "%d %d\n", len(seqs), length); err != nil {
+		return err
+	}
+	for i, s := range seqs {
+		if _, err := fmt.Fprintf(w, "%-10s %s\n", names[i], s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMS writes the sequences to w as one sample of Hudson's ms output:
+// the segregating sites, their positions scaled to (0, 1),
+// and the haplotypes. The positions have one more decimal than the digits
+// of the genome length, so that no two sites share a position.
+// The state of the first sequence at a site is written as 0,
+// any other base as 1; a state that is not a base is an error.
+func WriteMS(w io.Writer, seqs [][]byte) error {
+	seqs, err := bases(seqs)
+	if err != nil {
+		return err
+	}
+	sites := []int{}
+	if len(seqs) > 0 {
+		for k := range seqs[0] {
+			for _, s := range seqs[1:] {
+				if s[k] != seqs[0][k] {
+					sites = append(sites, k)
+					break
+				}
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\n//\nsegsites: %d\n", len(sites))
+	if len(sites) > 0 {
+		bw.WriteString("positions:")
+		length := float64(len(seqs[0]))
+		prec := len(strconv.Itoa(len(seqs[0]))) + 1
+		if prec < 6 {
+			prec = 6
+		}
+		for _, k := range sites {
+			fmt.Fprintf(bw, " %.*f", prec, (float64(k)+0.5)/length)
+		}
+		bw.WriteString("\n")
+		for _, s := range seqs {
+			for _, k := range sites {
+				if s[k] == seqs[0][k] {
+					bw.WriteByte('0')
+				} else {
+					bw.WriteByte('1')
+				}
+			}
+			bw.WriteString("\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteVCF writes the segregating sites of the sequences to w in VCF 4.2,
+// with one haploid sample per sequence on a single contig "genome".
+// The state of the first sequence is the reference allele, and the other
+// states are the alternate alleles in order of appearance. The parameters
+// in h are written as meta-information lines. Genome states are written
+// as bases; any other state, including N, is an error.
+func WriteVCF(w io.Writer, h *results.Header, names []string, seqs [][]byte) error {
+	seqs, err := bases(seqs)
+	if err != nil {
+		return err
+	}
+	length := 0
+	if len(seqs) > 0 {
+		length = len(seqs[0])
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("##fileformat=VCFv4.2\n##source=hgt\n")
+	for _, key := range h.Keys() {
+		v, _ := h.Get(key)
+		fmt.Fprintf(bw, "##%s=%s\n", key, v)
+	}
+	fmt.Fprintf(bw, "##contig=<ID=genome,length=%d>\n", length)
+	bw.WriteString("##FORMAT=<ID=GT,Number=1,Type=String,Description=\"Genotype\">\n")
+	bw.WriteString("#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT")
+	for _, name := range names {
+		bw.WriteString("\t" + name)
+	}
+	bw.WriteString("\n")
+
+	alleles := []byte{}
+	gt := make([]int, len(seqs))
+	for k := 0; k < length; k++ {
+		alleles = alleles[:0]
+		for i, s := range seqs {
+			a := 0
+			for a < len(alleles) && alleles[a] != s[k] {
+				a++
+			}
+			if a == len(alleles) {
+				alleles = append(alleles, s[k])
+			}
+			gt[i] = a
+		}
+		if len(alleles) < 2 {
+			continue
+		}
+
+		fmt.Fprintf(bw, "genome\t%d\t.\t%c\t", k+1, alleles[0])
+		for j, b := range alleles[1:] {
+			if j > 0 {
+				bw.WriteByte(',')
+			}
+			bw.WriteByte(b)
+		}
+		bw.WriteString("\t.\tPASS\t.\tGT")
+		for _, a := range gt {
+			fmt.Fprintf(bw, "\t%d", a)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// Write writes the sequences to w in format.
+// The parameters in h are written to the formats that have a header.
+func Write(w io.Writer, format string, h *results.Header, seqs [][]byte) error {
+	switch format {
+	case "fasta":
+		return WriteFASTA(w, Names(len(seqs)), seqs)
+	case "phylip":
+		return WritePHYLIP(w, Names(len(seqs)), seqs)
+	case "ms":
+		return WriteMS(w, seqs)
+	case "vcf":
+		return WriteVCF(w, h, Names(len(seqs)), seqs)
+	}
+	return fmt.Errorf("unknown export format: %s", format)
+}
+
+// Sample returns n of the sequences chosen at random by r,
+// or all of them if n is not positive or not less than their number.
+func Sample(seqs [][]byte, n int, r *rand.Rand) [][]byte {
+	if n <= 0 || n >= len(seqs) {
+		return seqs
+	}
+	sample := make([][]byte, n)
+	for i, j := range r.Perm(len(seqs))[:n] {
+		sample[i] = seqs[j]
+	}
+	return sample
+}
+
+// Save writes a sample of the sequences of replicate i to name plus the
+// extension of the export format of cfg, if any. The sample is drawn from
+// the Export stream of the replicate, so that exporting does not change
+// the other results of a run.
+func Save(cfg *config.Config, name string, i int, seqs [][]byte) error {
+	if cfg.Export == "" {
+		return nil
+	}
+	ext, ok := Formats[cfg.Export]
+	if !ok {
+		return fmt.Errorf("unknown export format: %s", cfg.Export)
+	}
+
+	f, err := os.Create(name + ext)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	seqs = Sample(seqs, cfg.ExportSample, rng.New(cfg.Seed, rng.Export, i))
+	if cfg.Export == "ms" {
+		// the command and seed lines of an ms run
+		if _, err := fmt.Fprintf(f, "hgt %d 1\n%d\n", len(seqs), cfg.Seed); err != nil {
+			return err
+		}
+	}
+	h := cfg.Header()
+	h.Add("replicate", i)
+	return Write(f, cfg.Export, h, seqs)
+}
@@ -0,0 +1,179 @@
+package seqio
+
+import (
+	"bytes"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/results"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testSeqs mixes the state codes, upper and lower case. Sites 1, 2 and 4
+// segregate, and site 2 has three alleles.
+func testSeqs() [][]byte {
+	return [][]byte{{0, 1, 2, 3}, []byte("aGgA"), []byte("TTGA")}
+}
+
+func TestBase(t *testing.T) {
+	tests := []struct {
+		state byte
+		want  byte
+		ok    bool
+	}{
+		{'A', 'A', true},
+		{'C', 'C', true},
+		{'G', 'G', true},
+		{'T', 'T', true},
+		{'a', 'A', true},
+		{'c', 'C', true},
+		{'g', 'G', true},
+		{'t', 'T', true},
+		{0, 'A', true},
+		{1, 'C', true},
+		{2, 'G', true},
+		{3, 'T', true},
+		{4, 0, false},
+		{'N', 0, false},
+		{'n', 0, false},
+		{'-', 0, false},
+		{'U', 0, false},
+	}
+	for _, test := range tests {
+		b, ok := base(test.state)
+		if b != test.want || ok != test.ok {
+			t.Errorf("base(%d) = %q, %v, want %q, %v", test.state, b, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	h := &results.Header{}
+	h.Add("size", 100)
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"fasta", ">seq0\nACGT\n>seq1\nAGGA\n>seq2\nTTGA\n"},
+		{"phylip", "3 4\nseq0       ACGT\nseq1       AGGA\nseq2       TTGA\n"},
+		{"ms", "\n//\nsegsites: 3\npositions: 0.125000 0.375000 0.875000\n000\n011\n111\n"},
+		{"vcf", "##fileformat=VCFv4.2\n##source=hgt\n##size=100\n" +
+			"##contig=<ID=genome,length=4>\n" +
+			"##FORMAT=<ID=GT,Number=1,Type=String,Description=\"Genotype\">\n" +
+			"#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\tFORMAT\tseq0\tseq1\tseq2\n" +
+			"genome\t1\t.\tA\tT\t.\tPASS\t.\tGT\t0\t0\t1\n" +
+			"genome\t2\t.\tC\tG,T\t.\tPASS\t.\tGT\t0\t1\t2\n" +
+			"genome\t4\t.\tT\tA\t.\tPASS\t.\tGT\t0\t1\t1\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, test.format, h, testSeqs()); err != nil {
+			t.Errorf("%s: %v", test.format, err)
+			continue
+		}
+		if buf.String() != test.want {
+			t.Errorf("%s:\n%q\nwant\n%q", test.format, buf.String(), test.want)
+		}
+	}
+
+	if err := Write(&bytes.Buffer{}, "nexus", h, testSeqs()); err == nil {
+		t.Error("wrote an unknown format")
+	}
+}
+
+func TestWriteNotBases(t *testing.T) {
+	for format := range Formats {
+		for _, s := range []string{"ACNT", "AC-T", "acnt"} {
+			seqs := [][]byte{[]byte("ACGT"), []byte(s)}
+			var buf bytes.Buffer
+			if err := Write(&buf, format, &results.Header{}, seqs); err == nil {
+				t.Errorf("%s: wrote %q without error", format, s)
+			}
+		}
+	}
+}
+
+func TestWriteMSPositions(t *testing.T) {
+	tests := []struct {
+		length int
+		prec   int
+	}{
+		{4, 6},
+		{99999, 6},
+		{100000, 7},
+		{999999, 7},
+		{1000000, 8},
+	}
+	for _, test := range tests {
+		a := bytes.Repeat([]byte("A"), test.length)
+		b := append([]byte(nil), a...)
+		for _, k := range []int{0, 1, test.length - 2, test.length - 1} {
+			b[k] = 'T'
+		}
+		var buf bytes.Buffer
+		if err := WriteMS(&buf, [][]byte{a, b}); err != nil {
+			t.Fatal(err)
+		}
+
+		var positions []string
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasPrefix(line, "positions:") {
+				positions = strings.Fields(line)[1:]
+			}
+		}
+		if len(positions) != 4 {
+			t.Errorf("%d sites: positions %v, want 4", test.length, positions)
+			continue
+		}
+		last := 0.0
+		for _, p := range positions {
+			if i := strings.Index(p, "."); len(p)-i-1 != test.prec {
+				t.Errorf("%d sites: position %s, want %d decimals", test.length, p, test.prec)
+			}
+			x, err := strconv.ParseFloat(p, 64)
+			if err != nil || x <= last || x >= 1 {
+				t.Errorf("%d sites: positions %v are not increasing in (0, 1)", test.length, positions)
+				break
+			}
+			last = x
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seqio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "x_rep0")
+
+	cfg := config.Config{Size: 3, Length: 4, Seed: 7}
+	if err := Save(&cfg, name, 0, testSeqs()); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("saved %d files without an export format", len(files))
+	}
+
+	cfg.Export = "ms"
+	if err := Save(&cfg, name, 0, testSeqs()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(name + ".ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "hgt 3 1\n7\n\n//\nsegsites: 3\n"
+	if !strings.HasPrefix(string(b), want) {
+		t.Errorf("ms file starts %q, want %q", b, want)
+	}
+
+	cfg.Export = "nexus"
+	if err := Save(&cfg, name, 0, testSeqs()); err == nil {
+		t.Error("saved an unknown format")
+	}
+}
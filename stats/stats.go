@@ -0,0 +1,129 @@
+// Package stats accumulates the moments of the covariance series
+// of the replicates, so that partial results can be merged exactly.
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// NumCovs is the number of covariance series returned by CovCircle.
+const NumCovs = 5
+
+// CovNames are the names of the covariance series, in the order of CovCircle.
+var CovNames = []string{"scov", "rcov", "xy", "xsys", "smxy"}
+
+// Moment accumulates the count, mean, variance and range of a value.
+// Its fields are exported so that it can be saved in a checkpoint.
+type Moment struct {
+	N    int     // number of values
+	Mean float64 // mean of the values
+	M2   float64 // sum of squared deviations from the mean
+	Min  float64 // smallest value
+	Max  float64 // largest value
+}
+
+// Increment adds d using Welford's algorithm.
+func (m *Moment) Increment(d float64) {
+	m.N++
+	if m.N == 1 {
+		m.Min, m.Max = d, d
+	} else {
+		m.Min = math.Min(m.Min, d)
+		m.Max = math.Max(m.Max, d)
+	}
+	delta := d - m.Mean
+	m.Mean += delta / float64(m.N)
+	m.M2 += delta * (d - m.Mean)
+}
+
+// Merge adds the values accumulated in o, using the pairwise update
+// of Chan et al., so that merging partial moments gives the moments
+// of all the values.
+func (m *Moment) Merge(o Moment) {
+	if o.N == 0 {
+		return
+	}
+	if m.N == 0 {
+		*m = o
+		return
+	}
+	n := m.N + o.N
+	delta := o.Mean - m.Mean
+	m.Mean += delta * float64(o.N) / float64(n)
+	m.M2 += o.M2 + delta*delta*float64(m.N)*float64(o.N)/float64(n)
+	m.Min = math.Min(m.Min, o.Min)
+	m.Max = math.Max(m.Max, o.Max)
+	m.N = n
+}
+
+// Var returns the bias corrected variance.
+func (m *Moment) Var() float64 {
+	if m.N < 2 {
+		return math.NaN()
+	}
+	return m.M2 / float64(m.N-1)
+}
+
+// Sd returns the bias corrected standard deviation.
+func (m *Moment) Sd() float64 {
+	return math.Sqrt(m.Var())
+}
+
+// Se returns the standard error of the mean.
+func (m *Moment) Se() float64 {
+	return m.Sd() / math.Sqrt(float64(m.N))
+}
+
+// Covs accumulates the NumCovs covariance series of the replicates,
+// one Moment for every series and distance.
+type Covs struct {
+	Series [][]Moment // Series[i][l] is series i at distance l
+}
+
+// NewCovs returns an empty accumulator of series of maxl distances.
+func NewCovs(maxl int) *Covs {
+	c := &Covs{Series: make([][]Moment, NumCovs)}
+	for i := range c.Series {
+		c.Series[i] = make([]Moment, maxl)
+	}
+	return c
+}
+
+// MaxL returns the number of distances of the series.
+func (c *Covs) MaxL() int {
+	return len(c.Series[0])
+}
+
+// N returns the number of replicates accumulated,
+// or 0 if the series have no distances.
+func (c *Covs) N() int {
+	if c.MaxL() == 0 {
+		return 0
+	}
+	return c.Series[0][0].N
+}
+
+// Increment adds the covariance series of one replicate.
+func (c *Covs) Increment(scovs, rcovs, xyPL, xsysPL, smXYPL []float64) {
+	for l := 0; l < c.MaxL(); l++ {
+		c.Series[0][l].Increment(scovs[l])
+		c.Series[1][l].Increment(rcovs[l])
+		c.Series[2][l].Increment(xyPL[l])
+		c.Series[3][l].Increment(xsysPL[l])
+		c.Series[4][l].Increment(smXYPL[l])
+	}
+}
+
+// Merge adds the replicates accumulated in o.
+func (c *Covs) Merge(o *Covs) error {
+	if o.MaxL() != c.MaxL() {
+		return fmt.Errorf("cannot merge covariances of maxl %d and %d", o.MaxL(), c.MaxL())
+	}
+	for i := range c.Series {
+		for l := range c.Series[i] {
+			c.Series[i][l].Merge(o.Series[i][l])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func onePass(xs []float64) Moment {
+	var m Moment
+	for _, x := range xs {
+		m.Increment(x)
+	}
+	return m
+}
+
+func sameMoment(t *testing.T, name string, got, want Moment) {
+	t.Helper()
+	if got.N != want.N || !closeTo(got.Mean, want.Mean) || !closeTo(got.M2, want.M2) ||
+		got.N > 0 && (got.Min != want.Min || got.Max != want.Max) {
+		t.Errorf("%s: got %+v, want %+v", name, got, want)
+	}
+}
+
+func TestMomentIncrement(t *testing.T) {
+	m := onePass([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if m.N != 8 || m.Mean != 5 || !closeTo(m.M2, 32) || m.Min != 2 || m.Max != 9 {
+		t.Errorf("got %+v", m)
+	}
+	if !closeTo(m.Var(), 32.0/7) {
+		t.Errorf("Var() = %g, want %g", m.Var(), 32.0/7)
+	}
+	if !closeTo(m.Se(), m.Sd()/math.Sqrt(8)) {
+		t.Errorf("Se() = %g", m.Se())
+	}
+}
+
+func TestMomentUndefined(t *testing.T) {
+	var m Moment
+	if !math.IsNaN(m.Var()) {
+		t.Errorf("Var() of no values = %g, want NaN", m.Var())
+	}
+	m.Increment(3)
+	if !math.IsNaN(m.Var()) || !math.IsNaN(m.Sd()) {
+		t.Errorf("Var() of one value = %g, want NaN", m.Var())
+	}
+}
+
+func TestMomentMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		xs   []float64
+	}{
+		{"empty", nil},
+		{"one", []float64{1.5}},
+		{"two", []float64{-1, 3}},
+		{"several", []float64{0.1, 0.7, -2.3, 4.4, 4.4, 1e-3, 12}},
+		{"large offset", []float64{1e9 + 1, 1e9 + 2, 1e9 + 3, 1e9 + 4}},
+	}
+	for _, tt := range tests {
+		want := onePass(tt.xs)
+		// every split, including those with an empty side
+		for k := 0; k <= len(tt.xs); k++ {
+			m := onePass(tt.xs[:k])
+			m.Merge(onePass(tt.xs[k:]))
+			sameMoment(t, tt.name, m, want)
+		}
+	}
+}
+
+func TestMomentMergeMany(t *testing.T) {
+	xs := []float64{3, 1, 4, 1, 5, 9, 2, 6, 5, 3, 5}
+	var m Moment
+	for _, x := range xs {
+		m.Merge(onePass([]float64{x}))
+	}
+	sameMoment(t, "singletons", m, onePass(xs))
+}
+
+func series(maxl int, x float64) []float64 {
+	s := make([]float64, maxl)
+	for l := range s {
+		s[l] = x + float64(l)
+	}
+	return s
+}
+
+func TestCovsMerge(t *testing.T) {
+	const maxl = 4
+	values := []float64{0.5, 1, -2, 8, 3}
+	all, a, b := NewCovs(maxl), NewCovs(maxl), NewCovs(maxl)
+	for i, x := range values {
+		s := series(maxl, x)
+		all.Increment(s, s, s, s, s)
+		if i < 2 {
+			a.Increment(s, s, s, s, s)
+		} else {
+			b.Increment(s, s, s, s, s)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if a.N() != len(values) {
+		t.Errorf("N() = %d, want %d", a.N(), len(values))
+	}
+	for i := range all.Series {
+		for l := range all.Series[i] {
+			sameMoment(t, CovNames[i], a.Series[i][l], all.Series[i][l])
+		}
+	}
+
+	// merging into an empty accumulator copies
+	empty := NewCovs(maxl)
+	if err := empty.Merge(all); err != nil {
+		t.Fatal(err)
+	}
+	sameMoment(t, "empty", empty.Series[0][0], all.Series[0][0])
+}
+
+func TestCovsMergeMaxL(t *testing.T) {
+	if err := NewCovs(3).Merge(NewCovs(4)); err == nil {
+		t.Error("merging different maxl succeeded")
+	}
+}
+
+func TestCovsNoDistances(t *testing.T) {
+	c := NewCovs(0)
+	c.Increment(nil, nil, nil, nil, nil)
+	if c.N() != 0 {
+		t.Errorf("N() = %d, want 0", c.N())
+	}
+}
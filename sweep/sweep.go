@@ -0,0 +1,139 @@
+// Package sweep runs a simulation over a grid or a list of parameter values
+// and collects the results into one table.
+package sweep
+
+import (
+	"fmt"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/sched"
+	"io"
+)
+
+// Point is one combination of parameter values.
+type Point struct {
+	Size     int
+	Length   int
+	Fragment int
+	Mutation float64
+	Transfer float64
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("size = %d, length = %d, fragment = %d, mutation = %g, transfer = %g",
+		p.Size, p.Length, p.Fragment, p.Mutation, p.Transfer)
+}
+
+// Row is one sampled generation of a point.
+type Row struct {
+	Generation int
+	KS, VD     float64
+}
+
+// Points returns the points of the sweep described by cfg.Sweep.
+// In "grid" mode they are the Cartesian product of the value lists;
+// in "list" mode the i-th point takes the i-th value of every list,
+// and all lists must have the same length. A parameter without values
+// keeps its value in cfg.
+func Points(cfg *config.Config) ([]Point, error) {
+	s := &cfg.Sweep
+	sizes := ints(s.Size, cfg.Size)
+	lengths := ints(s.Length, cfg.Length)
+	fragments := ints(s.Fragment, cfg.Fragment)
+	mutations := floats(s.Mutation, cfg.Mutation)
+	transfers := floats(s.Transfer, cfg.Transfer)
+
+	points := []Point{}
+	switch s.Mode {
+	case "", "grid":
+		for _, size := range sizes {
+			for _, length := range lengths {
+				for _, fragment := range fragments {
+					for _, mutation := range mutations {
+						for _, transfer := range transfers {
+							points = append(points, Point{size, length, fragment, mutation, transfer})
+						}
+					}
+				}
+			}
+		}
+	case "list":
+		n := 1
+		for _, l := range []int{len(sizes), len(lengths), len(fragments), len(mutations), len(transfers)} {
+			if l > 1 {
+				if n > 1 && l != n {
+					return nil, fmt.Errorf("sweep lists have different lengths: %d and %d", n, l)
+				}
+				n = l
+			}
+		}
+		for i := 0; i < n; i++ {
+			p := Point{
+				Size:     sizes[i%len(sizes)],
+				Length:   lengths[i%len(lengths)],
+				Fragment: fragments[i%len(fragments)],
+				Mutation: mutations[i%len(mutations)],
+				Transfer: transfers[i%len(transfers)],
+			}
+			points = append(points, p)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sweep mode: %s", s.Mode)
+	}
+	return points, nil
+}
+
+func ints(values []int, def int) []int {
+	if len(values) == 0 {
+		return []int{def}
+	}
+	return values
+}
+
+func floats(values []float64, def float64) []float64 {
+	if len(values) == 0 {
+		return []float64{def}
+	}
+	return values
+}
+
+// Sink receives the rows of every point, in point order.
+type Sink func(i int, p Point, rows []Row) error
+
+// CSV writes the column names of a table keyed by the parameter values
+// to w, and returns a sink that writes the rows to it.
+func CSV(w io.Writer) (Sink, error) {
+	if _, err := fmt.Fprintln(w, "size,length,fragment,mutation,transfer,generation,ks,vd"); err != nil {
+		return nil, err
+	}
+	return func(i int, p Point, rows []Row) error {
+		return writeRows(w, p, rows)
+	}, nil
+}
+
+// Run calls fn for every point and its index, scheduling at most jobs
+// points at once, and passes the rows to the sinks. The rows of a point
+// are passed as soon as it and all the points before it are done,
+// so the sinks always get the points in order.
+func Run(points []Point, jobs int, fn func(i int, p Point) []Row, sinks ...Sink) error {
+	return sched.RunOrdered(0, len(points), jobs, func(i int) interface{} {
+		return fn(i, points[i])
+	}, func(i int, v interface{}) error {
+		for _, sink := range sinks {
+			if err := sink(i, points[i], v.([]Row)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func writeRows(w io.Writer, p Point, rows []Row) error {
+	for _, r := range rows {
+		_, err := fmt.Fprintf(w, "%d,%d,%d,%g,%g,%d,%g,%g\n",
+			p.Size, p.Length, p.Fragment, p.Mutation, p.Transfer, r.Generation, r.KS, r.VD)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package sweep
+
+import (
+	"bytes"
+	"github.com/mingzhi/gomain/config"
+	"reflect"
+	"testing"
+)
+
+func base() config.Config {
+	return config.Config{Size: 100, Length: 1000, Fragment: 10, Mutation: 1e-4, Transfer: 1e-5}
+}
+
+func TestPoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		sweep config.Sweep
+		want  []Point
+	}{
+		{"no sweep", config.Sweep{}, []Point{
+			{100, 1000, 10, 1e-4, 1e-5},
+		}},
+		{"grid", config.Sweep{Size: config.Ints{10, 20}, Transfer: config.Floats{0, 1e-3, 1e-2}}, []Point{
+			{10, 1000, 10, 1e-4, 0},
+			{10, 1000, 10, 1e-4, 1e-3},
+			{10, 1000, 10, 1e-4, 1e-2},
+			{20, 1000, 10, 1e-4, 0},
+			{20, 1000, 10, 1e-4, 1e-3},
+			{20, 1000, 10, 1e-4, 1e-2},
+		}},
+		{"grid by name", config.Sweep{Mode: "grid", Length: config.Ints{50, 60}, Fragment: config.Ints{5, 6}}, []Point{
+			{100, 50, 5, 1e-4, 1e-5},
+			{100, 50, 6, 1e-4, 1e-5},
+			{100, 60, 5, 1e-4, 1e-5},
+			{100, 60, 6, 1e-4, 1e-5},
+		}},
+		{"list", config.Sweep{Mode: "list", Size: config.Ints{10, 20, 30}, Mutation: config.Floats{1, 2, 3}}, []Point{
+			{10, 1000, 10, 1, 1e-5},
+			{20, 1000, 10, 2, 1e-5},
+			{30, 1000, 10, 3, 1e-5},
+		}},
+		{"list of one value", config.Sweep{Mode: "list", Size: config.Ints{10}, Length: config.Ints{50, 60}}, []Point{
+			{10, 50, 10, 1e-4, 1e-5},
+			{10, 60, 10, 1e-4, 1e-5},
+		}},
+	}
+	for _, test := range tests {
+		cfg := base()
+		cfg.Sweep = test.sweep
+		got, err := Points(&cfg)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPointsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		sweep config.Sweep
+	}{
+		{"lists of different lengths", config.Sweep{Mode: "list", Size: config.Ints{10, 20}, Length: config.Ints{1, 2, 3}}},
+		{"unknown mode", config.Sweep{Mode: "random", Size: config.Ints{10, 20}}},
+	}
+	for _, test := range tests {
+		cfg := base()
+		cfg.Sweep = test.sweep
+		if points, err := Points(&cfg); err == nil {
+			t.Errorf("%s: got %v, want an error", test.name, points)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	points := []Point{{Size: 1}, {Size: 2}, {Size: 3}, {Size: 4}}
+	var buf bytes.Buffer
+	sink, err := CSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := []int{}
+	err = Run(points, 3, func(i int, p Point) []Row {
+		return []Row{{Generation: p.Size, KS: float64(i)}}
+	}, sink, func(i int, p Point, rows []Row) error {
+		order = append(order, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []int{0, 1, 2, 3}) {
+		t.Errorf("sink got the points in order %v", order)
+	}
+	want := "size,length,fragment,mutation,transfer,generation,ks,vd\n" +
+		"1,0,0,0,0,1,0,0\n2,0,0,0,0,2,1,0\n3,0,0,0,0,3,2,0\n4,0,0,0,0,4,3,0\n"
+	if buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}
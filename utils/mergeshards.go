@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/stats"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shardKeys are the header keys that may differ between the shards of a run.
+var shardKeys = map[string]bool{
+	"shard":      true,
+	"replicates": true,
+	"checkpoint": true,
+	"command":    true,
+	"time":       true,
+	"host":       true,
+}
+
+// shard is the partial result of one shard of a job array run.
+type shard struct {
+	index, count int
+	replicates   int
+	header       *results.Header
+	moments      *stats.Covs
+}
+
+// MergeShards combines the shards of a job array run of fwd hpc or coals
+// into the _d.csv, _covs.csv and _moments.csv files of a single run.
+// The moments are merged from their counts, means and sums of squared
+// deviations, so the result does not depend on how the replicates were split.
+func MergeShards(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	prefix := fs.String("prefix", "test", "prefix of the sharded run")
+	fs.Parse(args)
+
+	filenames, err := filepath.Glob(*prefix + "_shard*_moments.csv")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(filenames) == 0 {
+		log.Fatalf("no shards found for prefix %s", *prefix)
+	}
+
+	shards := []shard{}
+	for _, filename := range filenames {
+		s, err := readShard(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		shards = append(shards, s)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].index < shards[j].index })
+
+	if err := checkShards(shards); err != nil {
+		log.Fatal(err)
+	}
+
+	// merge the moments in shard order
+	moments := stats.NewCovs(shards[0].moments.MaxL())
+	total := 0
+	for _, s := range shards {
+		if err := moments.Merge(s.moments); err != nil {
+			log.Fatal(err)
+		}
+		total += s.replicates
+	}
+
+	// the parameters of shard 0 with the provenance of all the shards
+	var info *config.Info
+	ps := []config.Provenance{}
+	for _, s := range shards {
+		si, err := config.ParseInfo(s.header)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if info == nil {
+			info = si
+		}
+		ps = append(ps, si.Provenance)
+	}
+	p, err := config.MergeProvenance(ps)
+	if err != nil {
+		log.Fatal(err)
+	}
+	info.Config.Shard = ""
+	h := info.Config.HeaderWith(p)
+	config.AddMerge(h)
+	h.Add("replicates", total)
+
+	// concatenate the d files in shard order
+	dfile, err := results.CreateD(*prefix+"_d.csv", h)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dfile.Close()
+	for _, s := range shards {
+		filename := fmt.Sprintf("%s_shard%d_d.csv", *prefix, s.index)
+		n, err := copyRows(dfile, filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n != s.replicates {
+			log.Fatalf("%s: %d rows, but the shard has %d replicates", filename, n, s.replicates)
+		}
+	}
+
+	if err := results.WriteCovs(*prefix+"_covs.csv", h, moments); err != nil {
+		log.Fatal(err)
+	}
+	if err := results.WriteMoments(*prefix+"_moments.csv", h, moments); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Merged %d shards, %d replicates\n", len(shards), total)
+}
+
+// readShard reads the moments file of a shard.
+func readShard(filename string) (shard, error) {
+	s := shard{}
+	h, moments, err := results.ReadMoments(filename)
+	if err != nil {
+		return s, err
+	}
+	s.header, s.moments = h, moments
+
+	v, ok := h.Get("shard")
+	if !ok {
+		return s, fmt.Errorf("%s: no shard in header", filename)
+	}
+	if _, err := fmt.Sscanf(v, "%d/%d", &s.index, &s.count); err != nil {
+		return s, fmt.Errorf("%s: bad shard %s", filename, v)
+	}
+	v, _ = h.Get("replicates")
+	if s.replicates, err = strconv.Atoi(v); err != nil {
+		return s, fmt.Errorf("%s: bad replicates %s", filename, v)
+	}
+	return s, nil
+}
+
+// checkShards checks that the shards are all the shards of one run.
+func checkShards(shards []shard) error {
+	count := shards[0].count
+	if len(shards) != count {
+		return fmt.Errorf("found %d shards, expected %d", len(shards), count)
+	}
+	first := shards[0].header
+	for i, s := range shards {
+		if s.index != i || s.count != count {
+			return fmt.Errorf("shard %d/%d is missing", i, count)
+		}
+		if s.moments.MaxL() != shards[0].moments.MaxL() {
+			return fmt.Errorf("shard %d has a different maxl", i)
+		}
+		if len(s.header.Keys()) != len(first.Keys()) {
+			return fmt.Errorf("shard %d has different parameters", i)
+		}
+		for _, key := range first.Keys() {
+			if shardKeys[key] {
+				continue
+			}
+			a, _ := first.Get(key)
+			b, ok := s.header.Get(key)
+			if !ok || a != b {
+				return fmt.Errorf("shard %d has %s: %s, shard 0 has %s", i, key, b, a)
+			}
+		}
+	}
+	return nil
+}
+
+// copyRows copies the data rows of a d file to w, and returns their number.
+func copyRows(w io.Writer, filename string) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, scanner.Err()
+}
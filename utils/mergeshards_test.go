@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/stats"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// shardHeader returns the header of shard i of count of a run with cfg.
+func shardHeader(cfg config.Config, i, count, replicates int, host string) *results.Header {
+	p := config.Provenance{Command: "gomain fwd hpc -shard auto", Time: "t", Revision: "abc", HGT: "v1", Go: "go1", Host: host}
+	h := cfg.HeaderWith(p)
+	h.Add("shard", fmt.Sprintf("%d/%d", i, count))
+	h.Add("replicates", replicates)
+	return h
+}
+
+func testShards(cfg config.Config) ([]shard, *stats.Covs) {
+	all := stats.NewCovs(3)
+	shards := []shard{}
+	for i, reps := range []int{3, 2} {
+		c := stats.NewCovs(3)
+		for r := 0; r < reps; r++ {
+			x := float64(i*10 + r)
+			row := []float64{x, x * x, 1, -x, 0.5}
+			c.Increment(row, row, row, row, row)
+			all.Increment(row, row, row, row, row)
+		}
+		shards = append(shards, shard{index: i, count: 2, replicates: reps,
+			header: shardHeader(cfg, i, 2, reps, fmt.Sprintf("node%d", i)), moments: c})
+	}
+	return shards, all
+}
+
+func TestCheckShards(t *testing.T) {
+	cfg := config.Config{Size: 100, Length: 1000, Fragment: 10, MaxL: 3, Reps: 5, Seed: 1}
+	shards, _ := testShards(cfg)
+	if err := checkShards(shards); err != nil {
+		t.Fatalf("shards of one run: %v", err)
+	}
+
+	if err := checkShards(shards[:1]); err == nil {
+		t.Error("accepted a missing shard")
+	}
+
+	other := cfg
+	other.Mutation = 1e-3
+	bad, _ := testShards(cfg)
+	bad[1].header = shardHeader(other, 1, 2, 2, "node1")
+	err := checkShards(bad)
+	if err == nil || !strings.Contains(err.Error(), "mutation") {
+		t.Errorf("shards with different mutation: error %v", err)
+	}
+}
+
+func TestMergeShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prefix := filepath.Join(dir, "run")
+
+	cfg := config.Config{Size: 100, Length: 1000, Fragment: 10, MaxL: 3, Reps: 5, Seed: 1}
+	shards, all := testShards(cfg)
+	for _, s := range shards {
+		name := fmt.Sprintf("%s_shard%d", prefix, s.index)
+		if err := results.WriteMoments(name+"_moments.csv", s.header, s.moments); err != nil {
+			t.Fatal(err)
+		}
+		f, err := results.CreateD(name+"_d.csv", s.header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for r := 0; r < s.replicates; r++ {
+			results.WriteD(f, float64(s.index), float64(r))
+		}
+		f.Close()
+	}
+
+	MergeShards([]string{"-prefix", prefix})
+
+	h, merged, err := results.ReadMoments(prefix + "_moments.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range all.Series {
+		for l := range all.Series[i] {
+			want, got := all.Series[i][l], merged.Series[i][l]
+			if got.N != want.N || !closeTo(got.Mean, want.Mean) || !closeTo(got.M2, want.M2) ||
+				got.Min != want.Min || got.Max != want.Max {
+				t.Errorf("%s[%d] = %+v, want %+v", stats.CovNames[i], l, got, want)
+			}
+		}
+	}
+	for key, want := range map[string]string{"replicates": "5", "host": "node0; node1", "revision": "abc"} {
+		if v, _ := h.Get(key); v != want {
+			t.Errorf("%s = %q, want %q", key, v, want)
+		}
+	}
+	if _, ok := h.Get("shard"); ok {
+		t.Error("merged header has a shard")
+	}
+
+	_, ks, vd, err := results.ReadD(prefix + "_d.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ks, vd) != "[0 0 0 1 1] [0 1 2 0 1]" {
+		t.Errorf("merged d rows = %v, %v", ks, vd)
+	}
+}
@@ -1,98 +1,110 @@
-package main
+package utils
 
 import (
-	"encoding/csv"
 	"flag"
 	"fmt"
-	"github.com/mingzhi/gomath/stat/desc"
+	"github.com/mingzhi/gomain/config"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/stats"
 	"log"
-	"os"
 	"path"
-	"strconv"
+	"path/filepath"
 )
 
-var (
-	dir string // folder of files
-	num int    // number of files
-)
-
-type Moments struct {
-	Mean *desc.Mean
-	Sd   *desc.StandardDeviation
-}
+// MergeCovs reads covs files, given as arguments, as a -glob pattern,
+// or as the covs_%d.csv files of a folder, and writes the moments of
+// their covariance series. Each file is weighted by the counts of its
+// _n columns, and the standard deviations are pooled over all the
+// replicates. Only files in the layout of results.WriteCovs are read.
+func MergeCovs(args []string) {
+	fs := flag.NewFlagSet("merge-covs", flag.ExitOnError)
+	dir := fs.String("dir", "out", "folder of covs_%d.csv files")
+	num := fs.Int("num", 1000, "number of files in the folder")
+	glob := fs.String("glob", "", "pattern of the files to merge, instead of -dir and -num")
+	out := fs.String("out", "", "output file (default: <dir>_covs.csv)")
 
-func (m *Moments) Increment(v float64) {
-	m.Sd.Increment(v)
-	m.Mean.Increment(v)
-}
-
-func init() {
-	flag.StringVar(&dir, "dir", "out", "folder of files")
-	flag.IntVar(&num, "num", 1000, "number of files")
+	fs.Parse(args)
 
-	flag.Parse()
-}
-
-func main() {
-	momentArr := make([][]Moments, 5)
-	for i := 0; i < num; i++ {
-		filename := fmt.Sprintf("%s/covs_%d.csv", dir, i)
-
-		f, err := os.Open(filename)
+	filenames := fs.Args()
+	if *glob != "" {
+		matches, err := filepath.Glob(*glob)
 		if err != nil {
-			log.Panic(err)
+			log.Fatal(err)
 		}
+		if len(matches) == 0 {
+			log.Fatalf("no files match %s", *glob)
+		}
+		filenames = append(filenames, matches...)
+	}
+	if len(filenames) == 0 {
+		for i := 0; i < *num; i++ {
+			filenames = append(filenames, fmt.Sprintf("%s/covs_%d.csv", *dir, i))
+		}
+	}
 
-		reader := csv.NewReader(f)
-		reader.Comment = '#'
-
-		records, err := reader.ReadAll()
+	var first *config.Info
+	var merged *stats.Covs
+	ps := []config.Provenance{}
+	for _, filename := range filenames {
+		h, moments, err := results.ReadCovs(filename)
 		if err != nil {
-			log.Panic(err)
+			log.Fatal(err)
+		}
+		info, err := config.ParseInfo(h)
+		if err != nil {
+			log.Fatalf("%s: %v", filename, err)
 		}
 
-		for j, rec := range records {
-			if j >= len(momentArr[0]) {
-				for k := 0; k < len(momentArr); k++ {
-					m := Moments{
-						Mean: desc.NewMean(),
-						Sd:   desc.NewStandardDeviationWithBiasCorrection(),
-					}
-					momentArr[k] = append(momentArr[k], m)
-				}
-			}
-			for k := 0; k < len(momentArr); k++ {
-				v, err := strconv.ParseFloat(rec[k+1], 64)
-				if err != nil {
-					log.Panic(err)
-				}
-				momentArr[k][j].Increment(v)
-			}
+		if merged == nil {
+			first = info
+			merged = stats.NewCovs(moments.MaxL())
+		}
+		if err := checkCovs(&first.Config, &info.Config); err != nil {
+			log.Fatalf("%s and %s: %v", filenames[0], filename, err)
 		}
+		if err := merged.Merge(moments); err != nil {
+			log.Fatalf("%s and %s: %v", filenames[0], filename, err)
+		}
+		ps = append(ps, info.Provenance)
+	}
 
-		f.Close()
+	// the parameters of the first file, with the provenance of all the
+	// files and the merged replicate count
+	p, err := config.MergeProvenance(ps)
+	if err != nil {
+		log.Fatal(err)
 	}
+	cfg := first.Config
+	h := cfg.HeaderWith(p)
+	config.AddMerge(h)
+	h.Add("files", len(filenames))
+	h.Add("replicates", merged.N())
 
-	filename := fmt.Sprintf("%s_covs.csv", path.Base(dir))
-	f, err := os.Create(filename)
+	filename := *out
+	if filename == "" {
+		filename = fmt.Sprintf("%s_covs.csv", path.Base(*dir))
+	}
+	err = results.WriteCovs(filename, h, merged)
 	if err != nil {
 		log.Panic(err)
 	}
-	defer f.Close()
+}
 
-	for i := 0; i < len(momentArr[0]); i++ {
-		f.WriteString(fmt.Sprintf("%d,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g\n",
-			i,
-			momentArr[0][i].Mean.GetResult(),
-			momentArr[1][i].Mean.GetResult(),
-			momentArr[2][i].Mean.GetResult(),
-			momentArr[3][i].Mean.GetResult(),
-			momentArr[4][i].Mean.GetResult(),
-			momentArr[0][i].Sd.GetResult(),
-			momentArr[1][i].Sd.GetResult(),
-			momentArr[2][i].Sd.GetResult(),
-			momentArr[3][i].Sd.GetResult(),
-			momentArr[4][i].Sd.GetResult(),
-		))
+// checkCovs checks that two covs files were simulated with the same parameters.
+func checkCovs(a, b *config.Config) error {
+	switch {
+	case a.Size != b.Size:
+		return fmt.Errorf("size differs: %d and %d", a.Size, b.Size)
+	case a.Length != b.Length:
+		return fmt.Errorf("length differs: %d and %d", a.Length, b.Length)
+	case a.Fragment != b.Fragment:
+		return fmt.Errorf("fragment differs: %d and %d", a.Fragment, b.Fragment)
+	case a.Mutation != b.Mutation:
+		return fmt.Errorf("mutation differs: %g and %g", a.Mutation, b.Mutation)
+	case a.Transfer != b.Transfer:
+		return fmt.Errorf("transfer differs: %g and %g", a.Transfer, b.Transfer)
+	case a.Linear != b.Linear:
+		return fmt.Errorf("genome differs: %s and %s", config.GenomeMode(a.Linear), config.GenomeMode(b.Linear))
 	}
+	return nil
 }
@@ -0,0 +1,98 @@
+package window
+
+import (
+	"fmt"
+	"github.com/mingzhi/gomain/cov"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/gomain/sched"
+	"github.com/mingzhi/gomain/stats"
+	"github.com/mingzhi/hgt/covs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Sliding returns the windows of size sites starting every step sites
+// along a genome of the given length, the last one ending at most at
+// the end of the genome. A step of 0 gives non-overlapping windows.
+func Sliding(length, size, step int) []Window {
+	if step <= 0 {
+		step = size
+	}
+	windows := []Window{}
+	for start := 0; start+size <= length; start += step {
+		windows = append(windows, Window{start, start + size})
+	}
+	return windows
+}
+
+// Track is the ks, vd and covariance series of a window of a genome scan.
+type Track struct {
+	Stat
+	Series [stats.NumCovs][]float64 // in the order of stats.CovNames
+}
+
+// Scan returns the ks, vd and covariance series up to distance maxl of
+// every window, on at most sched.Jobs(jobs) goroutines. n is passed on
+// to covs.NewCMatrix. A window is a segment of the genome, so its series
+// are those of a linear genome whatever the genome mode.
+func Scan(n int, dmatrix [][]int, windows []Window, maxl, jobs int) []Track {
+	split := Split(dmatrix, windows)
+	tracks := make([]Track, len(windows))
+	sched.Run(len(windows), jobs, func(i int) {
+		w, m := windows[i], split[i]
+		t := &tracks[i]
+		t.Window = w
+		t.KS, t.VD = covs.NewCMatrix(n, w.Length(), m).D()
+		s := &t.Series
+		s[0], s[1], s[2], s[3], s[4] = cov.Linear(m, w.Length(), maxl)
+	})
+	return tracks
+}
+
+// WriteScan writes the tracks of a genome scan to two tables: ks and vd
+// of every window to <prefix>_scan.csv, and the covariance series of
+// every window and distance to <prefix>_scan_covs.csv. As in BED,
+// start is 0-based and end exclusive.
+func WriteScan(prefix string, h *results.Header, tracks []Track) error {
+	f, err := os.Create(prefix + "_scan.csv")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := h.Write(f); err != nil {
+		return err
+	}
+	if _, err := f.WriteString("#start, end, ks, vd\n"); err != nil {
+		return err
+	}
+	for _, t := range tracks {
+		if _, err := fmt.Fprintf(f, "%d,%d,%g,%g\n", t.Start, t.End, t.KS, t.VD); err != nil {
+			return err
+		}
+	}
+
+	c, err := os.Create(prefix + "_scan_covs.csv")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := h.Write(c); err != nil {
+		return err
+	}
+	if _, err := c.WriteString("#start, end, dist, " + strings.Join(stats.CovNames, ", ") + "\n"); err != nil {
+		return err
+	}
+	for _, t := range tracks {
+		for l := range t.Series[0] {
+			fields := []string{strconv.Itoa(t.Start), strconv.Itoa(t.End), strconv.Itoa(l)}
+			for _, s := range t.Series {
+				fields = append(fields, fmt.Sprintf("%g", s[l]))
+			}
+			if _, err := fmt.Fprintln(c, strings.Join(fields, ",")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
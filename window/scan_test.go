@@ -0,0 +1,108 @@
+package window
+
+import (
+	"github.com/mingzhi/gomain/cov"
+	"github.com/mingzhi/gomain/results"
+	"github.com/mingzhi/hgt/covs"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSliding(t *testing.T) {
+	tests := []struct {
+		name               string
+		length, size, step int
+		want               []Window
+	}{
+		{"step divides", 10, 4, 3, []Window{{0, 4}, {3, 7}, {6, 10}}},
+		{"step does not divide", 12, 4, 3, []Window{{0, 4}, {3, 7}, {6, 10}}},
+		{"last window at the end", 13, 4, 3, []Window{{0, 4}, {3, 7}, {6, 10}, {9, 13}}},
+		{"step of one", 5, 3, 1, []Window{{0, 3}, {1, 4}, {2, 5}}},
+		{"step as window", 10, 4, 4, []Window{{0, 4}, {4, 8}}},
+		{"no step", 10, 4, 0, []Window{{0, 4}, {4, 8}}},
+		{"step past window", 12, 3, 5, []Window{{0, 3}, {5, 8}}},
+		{"step past genome", 10, 3, 20, []Window{{0, 3}}},
+		{"window as genome", 10, 10, 3, []Window{{0, 10}}},
+		{"window past genome", 10, 11, 3, []Window{}},
+	}
+	for _, test := range tests {
+		got := Sliding(test.length, test.size, test.step)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Sliding(%d, %d, %d) = %v, want %v", test.name, test.length, test.size, test.step, got, test.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	length, maxl := 70, 8
+	dmatrix := randomDiffs(8, length, 0.2, r)
+	windows := Sliding(length, 16, 5)
+
+	for _, jobs := range []int{1, 3} {
+		tracks := Scan(len(dmatrix), dmatrix, windows, maxl, jobs)
+		if len(tracks) != len(windows) {
+			t.Fatalf("%d jobs: %d tracks for %d windows", jobs, len(tracks), len(windows))
+		}
+		for i, w := range windows {
+			m := filter(dmatrix, w)
+			ks, vd := covs.NewCMatrix(len(dmatrix), w.Length(), m).D()
+			got := tracks[i]
+			if got.Window != w || !same(got.KS, ks) || !same(got.VD, vd) {
+				t.Errorf("%d jobs, window %v: %+v, want ks %g, vd %g", jobs, w, got.Stat, ks, vd)
+			}
+
+			var want [5][]float64
+			want[0], want[1], want[2], want[3], want[4] = cov.Linear(m, w.Length(), maxl)
+			for j := range want {
+				if !reflect.DeepEqual(got.Series[j], want[j]) {
+					t.Errorf("%d jobs, window %v, series %d: %v, want %v", jobs, w, j, got.Series[j], want[j])
+				}
+			}
+		}
+	}
+}
+
+func TestWriteScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "window")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prefix := filepath.Join(dir, "x")
+
+	h := &results.Header{}
+	h.Add("size", 100)
+	tracks := []Track{
+		{Stat: Stat{Window{0, 4}, 0.5, 0.25}},
+		{Stat: Stat{Window{3, 7}, 0.125, 0}},
+	}
+	for i := range tracks {
+		for j := range tracks[i].Series {
+			tracks[i].Series[j] = []float64{float64(i), float64(j) / 2}
+		}
+	}
+	if err := WriteScan(prefix, h, tracks); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"_scan.csv": "#size: 100\n#start, end, ks, vd\n0,4,0.5,0.25\n3,7,0.125,0\n",
+		"_scan_covs.csv": "#size: 100\n#start, end, dist, scov, rcov, xy, xsys, smxy\n" +
+			"0,4,0,0,0,0,0,0\n0,4,1,0,0.5,1,1.5,2\n" +
+			"3,7,0,1,1,1,1,1\n3,7,1,0,0.5,1,1.5,2\n",
+	}
+	for suffix, content := range want {
+		b, err := ioutil.ReadFile(prefix + suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s:\n%s\nwant\n%s", suffix, b, content)
+		}
+	}
+}
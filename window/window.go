@@ -0,0 +1,99 @@
+// Package window splits the sorted diffs of pairs of genomes into windows
+// of sites, so that the statistics of many windows come from one diff
+// list per pair instead of one pass over the genomes per window.
+package window
+
+import (
+	"fmt"
+	"github.com/mingzhi/hgt/covs"
+	"io"
+	"sort"
+)
+
+// Window is the range of sites [Start, End) of a genome.
+type Window struct {
+	Start, End int
+}
+
+// Length returns the number of sites of w.
+func (w Window) Length() int {
+	return w.End - w.Start
+}
+
+// Prefixes returns the windows [0, l) of the lengths.
+func Prefixes(lengths []int) []Window {
+	windows := make([]Window, len(lengths))
+	for i, l := range lengths {
+		windows[i] = Window{0, l}
+	}
+	return windows
+}
+
+// Tiles returns the non-overlapping windows of size sites along a genome
+// of the given length. Sites after the last whole window are left out.
+func Tiles(length, size int) []Window {
+	windows := []Window{}
+	for start := 0; start+size <= length; start += size {
+		windows = append(windows, Window{start, start + size})
+	}
+	return windows
+}
+
+// Split returns the diffs of every pair inside every window, with the
+// sites counted from the start of the window, as covs.NewCMatrix expects
+// them. The diffs of a pair must be sorted. The diffs of a window
+// starting at site 0 share the memory of dmatrix.
+func Split(dmatrix [][]int, windows []Window) [][][]int {
+	split := make([][][]int, len(windows))
+	for i, w := range windows {
+		split[i] = make([][]int, len(dmatrix))
+		for j, diff := range dmatrix {
+			b := sort.SearchInts(diff, w.Start)
+			e := b + sort.SearchInts(diff[b:], w.End)
+			if w.Start == 0 {
+				split[i][j] = diff[b:e]
+				continue
+			}
+			shifted := make([]int, e-b)
+			for k, site := range diff[b:e] {
+				shifted[k] = site - w.Start
+			}
+			split[i][j] = shifted
+		}
+	}
+	return split
+}
+
+// Stat is the ks and vd of a window.
+type Stat struct {
+	Window
+	KS, VD float64
+}
+
+// D returns the ks and vd of every window, from the diff matrix of the
+// pairs. n is passed on to covs.NewCMatrix.
+func D(n int, dmatrix [][]int, windows []Window) []Stat {
+	stats := make([]Stat, len(windows))
+	for i, m := range Split(dmatrix, windows) {
+		ks, vd := covs.NewCMatrix(n, windows[i].Length(), m).D()
+		stats[i] = Stat{windows[i], ks, vd}
+	}
+	return stats
+}
+
+// WriteHeader writes the column names of the table of WriteD.
+func WriteHeader(w io.Writer) error {
+	_, err := io.WriteString(w, "#generation, start, end, ks, vd\n")
+	return err
+}
+
+// WriteD writes the ks and vd of every window at a generation,
+// one row per window.
+func WriteD(w io.Writer, generation int, stats []Stat) error {
+	for _, s := range stats {
+		if _, err := fmt.Fprintf(w, "%d,%d,%d,%g,%g\n", generation, s.Start, s.End, s.KS, s.VD); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package window
+
+import (
+	"github.com/mingzhi/hgt/covs"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// randomDiffs returns the sorted diffs of n pairs on a genome of length sites,
+// each site differing with probability p.
+func randomDiffs(n, length int, p float64, r *rand.Rand) [][]int {
+	dmatrix := make([][]int, n)
+	for i := range dmatrix {
+		for k := 0; k < length; k++ {
+			if r.Float64() < p {
+				dmatrix[i] = append(dmatrix[i], k)
+			}
+		}
+	}
+	return dmatrix
+}
+
+// filter returns the diffs of every pair inside w, counted from its start,
+// by looking at every site.
+func filter(dmatrix [][]int, w Window) [][]int {
+	m := make([][]int, len(dmatrix))
+	for i, diff := range dmatrix {
+		m[i] = []int{}
+		for _, site := range diff {
+			if site >= w.Start && site < w.End {
+				m[i] = append(m[i], site-w.Start)
+			}
+		}
+	}
+	return m
+}
+
+// sameDiffs returns whether x and y have the same sites, taking
+// nil as empty.
+func sameDiffs(x, y [][]int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if len(x[i]) != len(y[i]) || len(x[i]) > 0 && !reflect.DeepEqual(x[i], y[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// same returns whether x and y are equal or both NaN, as the statistics
+// of an empty window are.
+func same(x, y float64) bool {
+	return x == y || math.IsNaN(x) && math.IsNaN(y)
+}
+
+func TestPrefixes(t *testing.T) {
+	got := Prefixes([]int{10, 0, 80})
+	want := []Window{{0, 10}, {0, 0}, {0, 80}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Prefixes = %v, want %v", got, want)
+	}
+	if got := Prefixes(nil); len(got) != 0 {
+		t.Errorf("Prefixes of no lengths = %v", got)
+	}
+}
+
+func TestTiles(t *testing.T) {
+	tests := []struct {
+		length, size int
+		want         []Window
+	}{
+		{9, 3, []Window{{0, 3}, {3, 6}, {6, 9}}},
+		{10, 3, []Window{{0, 3}, {3, 6}, {6, 9}}},
+		{11, 3, []Window{{0, 3}, {3, 6}, {6, 9}}},
+		{10, 10, []Window{{0, 10}}},
+		{10, 11, []Window{}},
+		{0, 3, []Window{}},
+	}
+	for _, test := range tests {
+		got := Tiles(test.length, test.size)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Tiles(%d, %d) = %v, want %v", test.length, test.size, got, test.want)
+		}
+	}
+}
+
+// testWindows are tiles with sites left over at the end, prefixes up to
+// and past the end of the genome, and windows overlapping and past it.
+func testWindows(length int) []Window {
+	windows := Tiles(length, 7)
+	windows = append(windows, Prefixes([]int{1, length / 2, length, length + 30})...)
+	return append(windows, Window{5, 5}, Window{length - 3, length + 10}, Window{length, length + 10})
+}
+
+func TestSplit(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	length := 50
+	dmatrix := randomDiffs(6, length, 0.3, r)
+	dmatrix = append(dmatrix, []int{}, nil, []int{0, length - 1})
+	windows := testWindows(length)
+
+	split := Split(dmatrix, windows)
+	if len(split) != len(windows) {
+		t.Fatalf("%d windows split into %d", len(windows), len(split))
+	}
+	for i, w := range windows {
+		if want := filter(dmatrix, w); !sameDiffs(split[i], want) {
+			t.Errorf("window %v: %v, want %v", w, split[i], want)
+		}
+	}
+}
+
+func TestD(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	length := 60
+	dmatrix := randomDiffs(10, length, 0.2, r)
+	windows := testWindows(length)
+
+	got := D(len(dmatrix), dmatrix, windows)
+	if len(got) != len(windows) {
+		t.Fatalf("%d stats for %d windows", len(got), len(windows))
+	}
+	for i, w := range windows {
+		ks, vd := covs.NewCMatrix(len(dmatrix), w.Length(), filter(dmatrix, w)).D()
+		if got[i].Window != w || !same(got[i].KS, ks) || !same(got[i].VD, vd) {
+			t.Errorf("window %v: %+v, want ks %g, vd %g", w, got[i], ks, vd)
+		}
+	}
+}